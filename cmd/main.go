@@ -27,7 +27,8 @@ import (
 const progName = "netflow_collector"
 
 var (
-	configFile = kingpin.Flag("config", "Path to the configuration file.").Default("config.yaml").String()
+	configFile    = kingpin.Flag("config", "Path to the configuration file.").Default("config.yaml").String()
+	listEnrichers = kingpin.Flag("list-enrichers", "List names of available enrichers and exit.").Bool()
 )
 
 func main() {
@@ -39,6 +40,13 @@ func main() {
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
 
+	if *listEnrichers {
+		for _, name := range collector.EnricherNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
 	logger := promslog.New(promlogConfig)
 	logger.Info(fmt.Sprintf("Starting %s", progName), "version", version.Info(), "config", *configFile)
 	collector.SetBaseLogger(logger)
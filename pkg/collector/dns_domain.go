@@ -0,0 +1,128 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+type domainRewriteRule struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// domainExtractor derives domain from host name to reduce cardinality of labels.
+type domainExtractor struct {
+	// one of "registrable", "suffix" or "none"
+	mode   string
+	levels int
+	// whether to honor private section of public suffix list (e.g. compute-1.amazonaws.com)
+	privateSuffixes bool
+	rules           []domainRewriteRule
+}
+
+func newDomainExtractor(cfg map[string]interface{}) *domainExtractor {
+	d := &domainExtractor{
+		mode:            cfgString(cfg, "mode", "registrable"),
+		levels:          cfgInt(cfg, "levels", 2),
+		privateSuffixes: cfgBool(cfg, "private_suffixes", false),
+	}
+	switch d.mode {
+	case "registrable", "suffix", "none":
+	default:
+		panic(fmt.Sprintf("unsupported domain mode: %s", d.mode))
+	}
+	if d.levels < 1 {
+		panic("levels (if specified) must be a positive integer")
+	}
+	if r, ok := cfg["rewrite"]; ok {
+		rules, ok := r.([]interface{})
+		if !ok {
+			panic("rewrite (if specified) must be a list of rules")
+		}
+		for _, rule := range rules {
+			rm, ok := rule.(map[string]interface{})
+			if !ok {
+				panic("rewrite rule must be a mapping with match and replace keys")
+			}
+			d.rules = append(d.rules, domainRewriteRule{
+				re:      regexp.MustCompile(cfgString(rm, "match", "")),
+				replace: cfgString(rm, "replace", ""),
+			})
+		}
+	}
+	return d
+}
+
+func lastLabels(name string, n int) string {
+	labels := strings.Split(name, ".")
+	if len(labels) <= n {
+		return name
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+// icannRegistrableDomain is like publicsuffix.EffectiveTLDPlusOne, but only ICANN section of list is considered
+func icannRegistrableDomain(name string) string {
+	// start of the longest ICANN suffix, defaults to last label
+	suffix := strings.LastIndexByte(name, '.') + 1
+	// suffixes are taken from end of name, so that nothing is allocated
+	for i := len(name); i > 0; {
+		i = strings.LastIndexByte(name[:i], '.')
+		s := name[i+1:]
+		if ps, icann := publicsuffix.PublicSuffix(s); icann && ps == s {
+			suffix = i + 1
+		}
+		if i < 0 {
+			break
+		}
+	}
+	if suffix == 0 {
+		return name
+	}
+	return name[strings.LastIndexByte(name[:suffix-1], '.')+1:]
+}
+
+func (d *domainExtractor) extract(name string) string {
+	for _, rule := range d.rules {
+		if rule.re.MatchString(name) {
+			return rule.re.ReplaceAllString(name, rule.replace)
+		}
+	}
+	// placeholders such as "local" or "unknown" and IP addresses are kept as-is
+	if !strings.Contains(name, ".") || net.ParseIP(name) != nil {
+		return name
+	}
+	name = strings.ToLower(strings.TrimRight(name, "."))
+	switch d.mode {
+	case "registrable":
+		if !d.privateSuffixes {
+			return icannRegistrableDomain(name)
+		}
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+			return domain
+		}
+		return name
+	case "suffix":
+		return lastLabels(name, d.levels)
+	default:
+		return name
+	}
+}
@@ -0,0 +1,84 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainExtractor(t *testing.T) {
+	d := newDomainExtractor(map[string]interface{}{})
+	assert.Equal(t, "amazonaws.com", d.extract("ec2-1-2-3-4.compute-1.amazonaws.com"))
+	assert.Equal(t, "bbc.co.uk", d.extract("www.bbc.co.uk."))
+	assert.Equal(t, "unknown", d.extract("unknown"))
+	assert.Equal(t, "1.2.3.4", d.extract("1.2.3.4"))
+	assert.Equal(t, "co.uk", d.extract("co.uk"))
+	assert.Equal(t, "example.lan", d.extract("nas.home.example.lan"))
+
+	d = newDomainExtractor(map[string]interface{}{
+		"private_suffixes": true,
+	})
+	assert.Equal(t, "ec2-1-2-3-4.compute-1.amazonaws.com", d.extract("ec2-1-2-3-4.compute-1.amazonaws.com"))
+	assert.Equal(t, "example.com", d.extract("www.example.com"))
+
+	d = newDomainExtractor(map[string]interface{}{
+		"mode":   "suffix",
+		"levels": 3,
+		"rewrite": []interface{}{
+			map[string]interface{}{
+				"match":   `^.*\.(1e100\.net|googleusercontent\.com)$`,
+				"replace": "google",
+			},
+		},
+	})
+	assert.Equal(t, "compute-1.amazonaws.com", d.extract("ec2-1-2-3-4.compute-1.amazonaws.com"))
+	assert.Equal(t, "example.com", d.extract("example.com"))
+	assert.Equal(t, "google", d.extract("fra16s56-in-f14.1e100.net"))
+
+	assert.Panics(t, func() {
+		newDomainExtractor(map[string]interface{}{"mode": "tld"})
+	})
+}
+
+func TestReverseLookupDomain(t *testing.T) {
+	addr := startDNSServer(t, map[string]string{
+		"4.3.2.1.in-addr.arpa.": "ec2-1-2-3-4.compute-1.amazonaws.com.",
+	}, 0)
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{
+		"servers":       []interface{}{addr},
+		"lookup_remote": true,
+		"domain": map[string]interface{}{
+			"mode": "registrable",
+		},
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 10})
+	f.AddAttr("destination_ip", []byte{1, 2, 3, 4})
+	e.Enrich(f)
+	assert.Equal(t, "ec2-1-2-3-4.compute-1.amazonaws.com", *f.AsString("destination_dns"))
+	assert.Equal(t, "amazonaws.com", *f.AsString("destination_domain"))
+	assert.Equal(t, "local", *f.AsString("source_domain"))
+	// domain is derived once and cached along with name
+	assert.Equal(t, dnsName{name: "ec2-1-2-3-4.compute-1.amazonaws.com", domain: "amazonaws.com"},
+		e.cache.Get("1.2.3.4").Value())
+}
@@ -0,0 +1,169 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+	"google.golang.org/protobuf/proto"
+)
+
+// dnstapReceiver accepts dnstap frame streams (as sent by Unbound, CoreDNS, BIND, ...)
+// and reports every address from responses along with the name that was queried.
+type dnstapReceiver struct {
+	network  string
+	address  string
+	timeout  time.Duration
+	logger   *slog.Logger
+	listener net.Listener
+	// onAnswer is called for every A/AAAA record in response, with name from question section
+	onAnswer func(ip, name string)
+	// onMessage is called for every received frame with result of parsing
+	onMessage func(ok bool)
+}
+
+// parseListenAddress parses address in form of "unix:/path/to/socket" or "tcp:host:port"
+func parseListenAddress(s string) (string, string, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("invalid listen address: %s", s)
+	}
+	switch parts[0] {
+	case "unix", "tcp":
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("unsupported network in listen address: %s", s)
+	}
+}
+
+func (d *dnstapReceiver) listen() (err error) {
+	if d.network == "unix" {
+		// remove stale socket from previous run, but never anything else
+		fi, err := os.Lstat(d.address)
+		switch {
+		case err == nil && fi.Mode()&os.ModeSocket == 0:
+			return fmt.Errorf("%s exists and is not a socket", d.address)
+		case err == nil:
+			if err = os.Remove(d.address); err != nil {
+				return err
+			}
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+	d.listener, err = net.Listen(d.network, d.address)
+	return err
+}
+
+// serve accepts connections until context is cancelled
+func (d *dnstapReceiver) serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		_ = d.listener.Close()
+	}()
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			d.logger.Warn("unable to accept dnstap connection", "err", err)
+			continue
+		}
+		go d.handleConn(ctx, conn)
+	}
+}
+
+func (d *dnstapReceiver) handleConn(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer func() {
+		stop()
+		_ = conn.Close()
+	}()
+	r, err := dnstap.NewReader(conn, &dnstap.ReaderOptions{
+		Bidirectional: true,
+		Timeout:       d.timeout,
+	})
+	if err != nil {
+		d.logger.Warn("unable to open dnstap stream", "remote", conn.RemoteAddr(), "err", err)
+		return
+	}
+	d.logger.Debug("dnstap stream opened", "remote", conn.RemoteAddr())
+	buf := make([]byte, dnstap.MaxPayloadSize)
+	for {
+		n, err := r.ReadFrame(buf)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				d.logger.Warn("unable to read dnstap frame", "remote", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+		d.onMessage(d.handleFrame(buf[:n]))
+	}
+}
+
+func isDnstapResponse(t dnstap.Message_Type) bool {
+	switch t {
+	case dnstap.Message_AUTH_RESPONSE, dnstap.Message_RESOLVER_RESPONSE, dnstap.Message_CLIENT_RESPONSE,
+		dnstap.Message_FORWARDER_RESPONSE, dnstap.Message_STUB_RESPONSE, dnstap.Message_TOOL_RESPONSE:
+		return true
+	}
+	return false
+}
+
+func (d *dnstapReceiver) handleFrame(frame []byte) bool {
+	dt := &dnstap.Dnstap{}
+	if err := proto.Unmarshal(frame, dt); err != nil {
+		return false
+	}
+	msg := dt.GetMessage()
+	if dt.GetType() != dnstap.Dnstap_MESSAGE || msg == nil {
+		return false
+	}
+	if !isDnstapResponse(msg.GetType()) || len(msg.GetResponseMessage()) == 0 {
+		// queries carry no answers, nothing to do
+		return true
+	}
+	resp := &dns.Msg{}
+	if err := resp.Unpack(msg.GetResponseMessage()); err != nil {
+		return false
+	}
+	if len(resp.Question) == 0 {
+		return true
+	}
+	// original query name is used rather than target of CNAME chain
+	name := strings.TrimRight(resp.Question[0].Name, ".")
+	for _, rr := range resp.Answer {
+		switch a := rr.(type) {
+		case *dns.A:
+			d.onAnswer(a.A.String(), name)
+		case *dns.AAAA:
+			d.onAnswer(a.AAAA.String(), name)
+		}
+	}
+	return true
+}
@@ -16,40 +16,70 @@ package collector
 
 import (
 	"bufio"
-	"context"
+	_ "embed"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
-	"os/exec"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/jellydator/ttlcache/v3"
 	"github.com/oschwald/geoip2-golang"
 	"github.com/rkosegi/ipfix-collector/pkg/public"
 )
 
 var (
+	//go:embed data/protocol-numbers
+	embeddedProtocols string
+
 	localCidrsStr = []string{
 		"0.0.0.0/8,10.0.0.0/8,100.64.0.0/10,127.0.0.0/8",
 		"169.254.0.0/16,172.16.0.0/12,192.0.0.0/24,192.0.2.0/24",
 		"192.88.99.0/24,192.168.0.0/16,198.18.0.0/15,198.51.100.0/24",
 		"203.0.113.0/24,224.0.0.0/4,233.252.0.0/24,240.0.0.0/4,255.255.255.255/32",
 	}
-	enrichers = map[string]public.Enricher{
-		"maxmind_country":  &maxmindCountry{},
-		"maxmind_asn":      &maxmindAsn{},
-		"interface_mapper": &interfaceName{},
-		"protocol_name":    &protocolName{},
-		"reverse_dns":      &reverseDNS{lookupRemote: true},
-		"host_alias":       &enrichHostAlias{},
-	}
 	localCidrs []*net.IPNet
 )
 
+// withDefaults wraps factory of built-in enricher, so that every new instance starts with default configuration.
+// Configuration from extensions, if there is any, replaces defaults as a whole.
+func withDefaults(factory public.EnricherFactory) public.EnricherFactory {
+	return func() public.Enricher {
+		e := factory()
+		e.Configure(map[string]interface{}{})
+		return e
+	}
+}
+
+// withDefaultsV2 is like withDefaults, but for built-in enrichers implementing public.EnricherV2
+func withDefaultsV2(factory public.EnricherV2Factory) public.EnricherV2Factory {
+	return func() public.EnricherV2 {
+		e := factory()
+		if err := e.Configure(map[string]interface{}{}); err != nil {
+			panic(err)
+		}
+		return e
+	}
+}
+
 func init() {
+	MustRegisterEnricher("maxmind_country", withDefaults(func() public.Enricher { return &maxmindCountry{} }))
+	MustRegisterEnricher("maxmind_asn", withDefaults(func() public.Enricher { return &maxmindAsn{} }))
+	MustRegisterEnricher("interface_mapper", withDefaults(func() public.Enricher { return &interfaceName{} }))
+	MustRegisterEnricher("protocol_name", withDefaults(func() public.Enricher { return &protocolName{} }))
+	MustRegisterEnricher("reverse_dns", withDefaults(func() public.Enricher { return &reverseDNS{lookupRemote: true} }))
+	MustRegisterEnricher("host_alias", withDefaults(func() public.Enricher { return &enrichHostAlias{} }))
+	MustRegisterEnricher("snmp_interface", withDefaults(func() public.Enricher { return &snmpInterface{} }))
+	MustRegisterEnricher("service_name", withDefaults(func() public.Enricher { return &serviceName{} }))
+	MustRegisterEnricher("local_hosts", withDefaults(func() public.Enricher { return &localHosts{} }))
+	MustRegisterEnricher("cloud_provider", withDefaults(func() public.Enricher { return &cloudProvider{} }))
+	MustRegisterEnricher("threat_intel", withDefaults(func() public.Enricher { return &threatIntel{} }))
+	MustRegisterEnricher("bgp_rib", withDefaults(func() public.Enricher { return &bgpRib{} }))
+	MustRegisterEnricher("community_id", withDefaults(func() public.Enricher { return &communityId{} }))
+	MustRegisterEnricherV2("exec", withDefaultsV2(func() public.EnricherV2 { return &execEnricher{} }))
+	MustRegisterEnricherV2("script", withDefaultsV2(func() public.EnricherV2 { return &scriptEnricher{} }))
+	MustRegisterEnricherV2("wasm", withDefaultsV2(func() public.EnricherV2 { return &wasmPlugin{} }))
+
 	localCidrs = make([]*net.IPNet, 0)
 	for _, s := range localCidrsStr {
 		for _, ips := range strings.Split(s, ",") {
@@ -61,10 +91,6 @@ func init() {
 	}
 }
 
-func getEnricher(name string) public.Enricher {
-	return enrichers[name]
-}
-
 type maxmindCountry struct {
 	logger *slog.Logger
 	isOpen bool
@@ -73,13 +99,8 @@ type maxmindCountry struct {
 }
 
 func (m *maxmindCountry) Configure(cfg map[string]interface{}) {
-	if dir, ok := cfg["mmdb_dir"]; !ok {
-		m.dir = "/usr/share/GeoIP"
-	} else {
-		m.dir = dir.(string)
-	}
+	m.dir = cfgString(cfg, "mmdb_dir", "/usr/share/GeoIP")
 	m.logger = baseLogger.With("component", "geoip_country")
-	m.logger.Info(fmt.Sprintf("using directory %s for Country GeoIP", m.dir))
 }
 
 func (m *maxmindCountry) Close() error {
@@ -99,6 +120,12 @@ func isLocalIp(addr net.IP) bool {
 }
 
 func (m *maxmindCountry) Enrich(flow *public.Flow) {
+	_, _ = m.EnrichHit(flow)
+}
+
+// EnrichHit implements public.HitReporter, flow is hit when country of any of its addresses was found in database
+func (m *maxmindCountry) EnrichHit(flow *public.Flow) (bool, error) {
+	hit := false
 	if m.isOpen {
 		sourceIp := flow.AsIp("source_ip")
 		destIp := flow.AsIp("destination_ip")
@@ -109,6 +136,8 @@ func (m *maxmindCountry) Enrich(flow *public.Flow) {
 			if country != nil {
 				if len(country.Country.IsoCode) == 0 {
 					country.Country.IsoCode = "Unknown"
+				} else {
+					hit = true
 				}
 				flow.AddAttr("source_country", country.Country.IsoCode)
 			}
@@ -120,14 +149,18 @@ func (m *maxmindCountry) Enrich(flow *public.Flow) {
 			if country != nil {
 				if len(country.Country.IsoCode) == 0 {
 					country.Country.IsoCode = "Unknown"
+				} else {
+					hit = true
 				}
 				flow.AddAttr("destination_country", country.Country.IsoCode)
 			}
 		}
 	}
+	return hit, nil
 }
 
 func (m *maxmindCountry) Start() error {
+	m.logger.Info(fmt.Sprintf("using directory %s for Country GeoIP", m.dir))
 	db, err := geoip2.Open(fmt.Sprintf("%s/GeoLite2-Country.mmdb", m.dir))
 	if err != nil {
 		return err
@@ -138,17 +171,48 @@ func (m *maxmindCountry) Start() error {
 }
 
 type interfaceName struct {
+	// mapping of ifIndex to interface name, used when sampler has no specific entry
 	mapping map[string]string
+	// per-sampler mapping of ifIndex to interface name, keyed by sampler address
+	samplers map[string]map[string]string
 }
 
 func (i *interfaceName) Close() error {
 	return nil
 }
 
+func toStringMap(in map[string]interface{}) map[string]string {
+	out := map[string]string{}
+	for k, v := range in {
+		s, ok := v.(string)
+		if !ok {
+			panic(fmt.Sprintf("value of %s must be a string", k))
+		}
+		out[k] = s
+	}
+	return out
+}
+
+// Configure accepts either flat map of ifIndex to name (applies to all samplers),
+// or map keyed by sampler address, where "default" key is used as a fallback.
 func (i *interfaceName) Configure(cfg map[string]interface{}) {
 	i.mapping = map[string]string{}
+	i.samplers = map[string]map[string]string{}
 	for k, v := range cfg {
-		i.mapping[k] = v.(string)
+		switch x := v.(type) {
+		case map[string]interface{}:
+			if k == "default" {
+				for ifIdx, name := range toStringMap(x) {
+					i.mapping[ifIdx] = name
+				}
+			} else {
+				i.samplers[k] = toStringMap(x)
+			}
+		case string:
+			i.mapping[k] = x
+		default:
+			panic(fmt.Sprintf("value of %s must be either interface name or mapping of interfaces", k))
+		}
 	}
 }
 
@@ -156,57 +220,107 @@ func (i *interfaceName) Start() error {
 	return nil
 }
 
+func (i *interfaceName) lookup(sampler string, ifIndex uint32) (string, bool) {
+	key := strconv.FormatUint(uint64(ifIndex), 10)
+	if m, ok := i.samplers[sampler]; ok {
+		if name, ok := m[key]; ok {
+			return name, true
+		}
+	}
+	name, ok := i.mapping[key]
+	return name, ok
+}
+
 func (i *interfaceName) Enrich(flow *public.Flow) {
+	sampler := ""
+	if ip := flow.AsIp("sampler"); ip != nil {
+		sampler = ip.String()
+	}
 	ii := flow.AsUint32("input_interface")
 	if ii != nil {
-		if name, ok := i.mapping[strconv.FormatUint(uint64(*ii), 10)]; ok {
+		if name, ok := i.lookup(sampler, *ii); ok {
 			flow.AddAttr("input_interface_name", name)
 		}
 	}
 
 	ii = flow.AsUint32("output_interface")
 	if ii != nil {
-		if name, ok := i.mapping[strconv.FormatUint(uint64(*ii), 10)]; ok {
+		if name, ok := i.lookup(sampler, *ii); ok {
 			flow.AddAttr("output_interface_name", name)
 		}
 	}
 }
 
 type protocolName struct {
+	unknownFormat string
+	onMissing     string
+	overrides     map[string]string
+	names         map[uint32]string
 }
 
 func (p *protocolName) Close() error {
 	return nil
 }
 
-func (p *protocolName) Configure(_ map[string]interface{}) {
-	// not used in this enricher
+func (p *protocolName) Configure(cfg map[string]interface{}) {
+	p.unknownFormat = cfgString(cfg, "unknown_format", "other (%d)")
+	p.onMissing = cfgString(cfg, "on_missing", "unknown")
+	p.overrides = map[string]string{}
+	if o, ok := cfg["overrides"]; ok {
+		m, ok := o.(map[string]interface{})
+		if !ok {
+			panic("overrides (if specified) must be a mapping of protocol number to name")
+		}
+		p.overrides = toStringMap(m)
+	}
+}
+
+// loadProtocols reads protocol numbers in /etc/protocols format
+func loadProtocols(r io.Reader, dst map[uint32]string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		num, err := strconv.ParseUint(fields[1], 10, 8)
+		if err != nil {
+			continue
+		}
+		dst[uint32(num)] = fields[0]
+	}
 }
 
 func (p *protocolName) Start() error {
+	p.names = map[uint32]string{}
+	loadProtocols(strings.NewReader(embeddedProtocols), p.names)
+	for k, v := range p.overrides {
+		num, err := strconv.ParseUint(k, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid protocol number: %s", k)
+		}
+		p.names[uint32(num)] = v
+	}
 	return nil
 }
 
 func (p *protocolName) Enrich(flow *public.Flow) {
-	protoName := ""
 	proto := flow.AsUint32("proto")
-	switch *proto {
-	case 0x01:
-		protoName = "icmp"
-
-	case 0x02:
-		protoName = "igmp"
-
-	case 0x06:
-		protoName = "tcp"
-
-	case 0x11:
-		protoName = "udp"
-
-	default:
-		protoName = fmt.Sprintf("other (%d)", *proto)
+	if proto == nil {
+		flow.AddAttr("proto_name", p.onMissing)
+		return
+	}
+	if name, ok := p.names[*proto]; ok {
+		flow.AddAttr("proto_name", name)
+	} else if strings.Contains(p.unknownFormat, "%") {
+		flow.AddAttr("proto_name", fmt.Sprintf(p.unknownFormat, *proto))
+	} else {
+		flow.AddAttr("proto_name", p.unknownFormat)
 	}
-	flow.AddAttr("proto_name", protoName)
 }
 
 type maxmindAsn struct {
@@ -217,13 +331,8 @@ type maxmindAsn struct {
 }
 
 func (m *maxmindAsn) Configure(cfg map[string]interface{}) {
-	if dir, ok := cfg["mmdb_dir"]; !ok {
-		m.dir = "/usr/share/GeoIP"
-	} else {
-		m.dir = dir.(string)
-	}
+	m.dir = cfgString(cfg, "mmdb_dir", "/usr/share/GeoIP")
 	m.logger = baseLogger.With("component", "geoip_asn")
-	m.logger.Info(fmt.Sprintf("using directory %s for ASN GeoIP", m.dir))
 }
 
 func (m *maxmindAsn) Close() error {
@@ -234,6 +343,7 @@ func (m *maxmindAsn) Close() error {
 }
 
 func (m *maxmindAsn) Start() error {
+	m.logger.Info(fmt.Sprintf("using directory %s for ASN GeoIP", m.dir))
 	db, err := geoip2.Open(fmt.Sprintf("%s/GeoLite2-ASN.mmdb", m.dir))
 	if err != nil {
 		return err
@@ -244,6 +354,12 @@ func (m *maxmindAsn) Start() error {
 }
 
 func (m *maxmindAsn) Enrich(flow *public.Flow) {
+	_, _ = m.EnrichHit(flow)
+}
+
+// EnrichHit implements public.HitReporter, flow is hit when AS of any of its addresses was found in database
+func (m *maxmindAsn) EnrichHit(flow *public.Flow) (bool, error) {
+	hit := false
 	if m.isOpen {
 		for _, dir := range []string{"source", "destination"} {
 			ip := flow.AsIp(dir + "_ip")
@@ -253,182 +369,11 @@ func (m *maxmindAsn) Enrich(flow *public.Flow) {
 					if len(asn.AutonomousSystemOrganization) > 0 {
 						flow.AddAttr(dir+"_asn_org", asn.AutonomousSystemOrganization)
 						flow.AddAttr(dir+"_asn_num", asn.AutonomousSystemNumber)
+						hit = true
 					}
 				}
 			}
 		}
 	}
-}
-
-type reverseDNS struct {
-	ttl   time.Duration
-	cache *ttlcache.Cache[string, string]
-
-	tailPiHole    bool
-	piHoleResults *ttlcache.Cache[string, string]
-
-	lookupLocal  bool
-	lookupRemote bool
-	ipAsUnknown  bool
-	logger       *slog.Logger
-}
-
-func (m *reverseDNS) Configure(cfg map[string]interface{}) {
-	tailPiholeObject, ok := cfg["tail_pihole"]
-	if ok {
-		m.tailPiHole, ok = tailPiholeObject.(bool)
-		if !ok {
-			panic("tail_pihole (if specified) must be a boolean, e.g. true (or false)")
-		}
-	}
-
-	lookupLocal, ok := cfg["lookup_local"]
-	if ok {
-		m.lookupLocal, ok = lookupLocal.(bool)
-		if !ok {
-			panic("lookup_local (if specified) must be a boolean, e.g. true (or false)")
-		}
-	}
-
-	lookupRemote, ok := cfg["lookup_remote"]
-	if ok {
-		m.lookupRemote, ok = lookupRemote.(bool)
-		if !ok {
-			panic("lookup_remote (if specified) must be a boolean, e.g. true (or false)")
-		}
-	}
-
-	ipAsUnknown, ok := cfg["ip_as_unknown"]
-	if ok {
-		m.ipAsUnknown, ok = ipAsUnknown.(bool)
-		if !ok {
-			panic("ip_as_unknown (if specified) must be a boolean, e.g. true (or false)")
-		}
-	}
-
-	durationResult, ok := cfg["cache_duration"]
-	if !ok {
-		// if not found, set default
-		m.ttl = time.Hour
-		return
-	}
-
-	durationString, ok := durationResult.(string)
-	if !ok {
-		panic("cache_duration (if specified) must be a Go duration string, e.g. 1h")
-	}
-
-	var err error
-	m.ttl, err = time.ParseDuration(durationString)
-	if err != nil {
-		panic("cache_duration (if specified) must be a Go duration string, e.g. 1h")
-	}
-}
-
-func (m *reverseDNS) populateCacheWithPiHoleEntries(msgs io.Reader) {
-	// cache to hold the results
-	m.piHoleResults = ttlcache.New(
-		ttlcache.WithTTL[string, string](m.ttl), // IP to name
-	)
-	go m.piHoleResults.Start()
-
-	// cache to hold the DNS masq query session
-	dnsMasqCache := ttlcache.New(
-		ttlcache.WithTTL[string, string](time.Minute), // session ID to original query
-	)
-	go dnsMasqCache.Start()
-
-	scanner := bufio.NewScanner(msgs)
-	for scanner.Scan() {
-		bits := strings.Split(scanner.Text(), " ")
-		if len(bits) < 7 {
-			continue
-		}
-		sessionId := bits[1]
-		action := bits[3]
-		switch {
-		case strings.HasPrefix(action, "query"):
-			dnsMasqCache.Set(sessionId, bits[4], ttlcache.DefaultTTL)
-		case action == "cached", action == "reply":
-			resultIP := bits[6]
-			if bits[5] == "is" && resultIP != "<CNAME>" {
-				origQuery := dnsMasqCache.Get(sessionId)
-				if origQuery != nil {
-					m.piHoleResults.Set(resultIP, origQuery.Value(), ttlcache.DefaultTTL)
-					m.logger.Info("got entry", "tph-query", origQuery.Value(), "tph-result", resultIP)
-				}
-			}
-		}
-	}
-}
-
-func (m *reverseDNS) Close() error {
-	return nil
-}
-
-func (m *reverseDNS) reverseLookup(ip net.IP) string {
-	if isLocalIp(ip) {
-		if !m.lookupLocal {
-			return "local"
-		}
-	} else {
-		if !m.lookupRemote {
-			return "remote"
-		}
-	}
-
-	s := ip.String()
-	if m.tailPiHole {
-		piHoleResult := m.piHoleResults.Get(s)
-		if piHoleResult != nil {
-			return piHoleResult.Value()
-		}
-	}
-	return m.cache.Get(s).Value()
-}
-
-func (m *reverseDNS) Enrich(flow *public.Flow) {
-	flow.AddAttr("source_dns", m.reverseLookup(flow.AsIp("source_ip")))
-	flow.AddAttr("destination_dns", m.reverseLookup(flow.AsIp("destination_ip")))
-}
-
-func (m *reverseDNS) Start() error {
-	m.logger = baseLogger.With("component", "reverse_dns")
-	ctx := context.Background()
-	m.cache = ttlcache.New(
-		ttlcache.WithTTL[string, string](m.ttl),
-		ttlcache.WithDisableTouchOnHit[string, string](),
-		ttlcache.WithLoader[string, string](ttlcache.LoaderFunc[string, string](
-			func(c *ttlcache.Cache[string, string], key string) *ttlcache.Item[string, string] {
-				m.logger.Debug("cache lookup", "key", key)
-				result := "unknown"
-				if m.ipAsUnknown {
-					result = key
-				}
-				names, err := net.DefaultResolver.LookupAddr(ctx, key)
-				if err == nil && len(names) != 0 {
-					result = strings.TrimRight(names[0], ".")
-				}
-				m.logger.Debug("lookup result", "result", result)
-				return c.Set(key, result, ttlcache.DefaultTTL)
-			},
-		)),
-	)
-	go m.cache.Start()
-
-	if m.tailPiHole {
-		m.logger.Info("tailing pihole")
-		tph := exec.CommandContext(ctx, "pihole", "-t")
-		stdout, err := tph.StdoutPipe()
-		if err != nil {
-			return err
-		}
-		err = tph.Start()
-		if err != nil {
-			return err
-		}
-		go m.populateCacheWithPiHoleEntries(stdout)
-	}
-
-	return nil
+	return hit, nil
 }
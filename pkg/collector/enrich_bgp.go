@@ -0,0 +1,210 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+type bgpRoute struct {
+	prefix   string
+	originAs uint32
+	peerAs   uint32
+	asPath   string
+}
+
+// bgpRib maps addresses to routes loaded from MRT TABLE_DUMP_V2 RIB dump.
+type bgpRib struct {
+	logger         *slog.Logger
+	file           string
+	reloadInterval time.Duration
+	// whether to set source_as/destination_as when exporter did not provide them
+	setAs bool
+
+	mu      sync.RWMutex
+	routes  *prefixTrie[*bgpRoute]
+	modTime time.Time
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (b *bgpRib) Configure(cfg map[string]interface{}) {
+	b.file = cfgString(cfg, "file", "")
+	b.reloadInterval = cfgPositiveDuration(cfg, "reload_interval", time.Hour)
+	b.setAs = cfgBool(cfg, "set_as", false)
+}
+
+func (b *bgpRib) Start() error {
+	b.logger = baseLogger.With("component", "bgp_rib")
+	if len(b.file) == 0 {
+		return fmt.Errorf("file with RIB dump must be specified")
+	}
+	if err := b.reload(); err != nil {
+		return err
+	}
+	b.stopCh = make(chan struct{})
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(b.reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.stopCh:
+				return
+			case <-ticker.C:
+				if fi, err := os.Stat(b.file); err == nil && fi.ModTime().Equal(b.modTime) {
+					continue
+				}
+				if err := b.reload(); err != nil {
+					b.logger.Warn("unable to reload RIB dump, keeping previous data", "err", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *bgpRib) Close() error {
+	if b.stopCh != nil {
+		close(b.stopCh)
+		b.wg.Wait()
+		b.stopCh = nil
+	}
+	return nil
+}
+
+// openRibFile opens RIB dump, transparently decompressing it based on file extension
+func openRibFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{gr, f}, nil
+	case strings.HasSuffix(path, ".bz2"):
+		return struct {
+			io.Reader
+			io.Closer
+		}{bzip2.NewReader(f), f}, nil
+	default:
+		return f, nil
+	}
+}
+
+func formatAsPath(path []uint32) string {
+	var sb strings.Builder
+	for i, as := range path {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.FormatUint(uint64(as), 10))
+	}
+	return sb.String()
+}
+
+// bestRibEntry selects route with the shortest AS path, first one wins on tie
+func bestRibEntry(entries []mrtRibEntry) *mrtRibEntry {
+	var best *mrtRibEntry
+	for i := range entries {
+		if len(entries[i].asPath) == 0 {
+			continue
+		}
+		if best == nil || entries[i].pathLen < best.pathLen {
+			best = &entries[i]
+		}
+	}
+	return best
+}
+
+func (b *bgpRib) reload() error {
+	fi, err := os.Stat(b.file)
+	if err != nil {
+		return err
+	}
+	f, err := openRibFile(b.file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	routes := newPrefixTrie[*bgpRoute]()
+	err = readMrtRib(f, func(prefix *net.IPNet, entries []mrtRibEntry) {
+		if best := bestRibEntry(entries); best != nil {
+			routes.insert(prefix, &bgpRoute{
+				prefix:   prefix.String(),
+				originAs: best.originAs(),
+				peerAs:   best.peerAs(),
+				asPath:   formatAsPath(best.asPath),
+			})
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.file, err)
+	}
+	b.mu.Lock()
+	b.routes = routes
+	b.modTime = fi.ModTime()
+	b.mu.Unlock()
+	b.logger.Info("RIB dump loaded", "path", b.file, "prefixes", routes.len())
+	return nil
+}
+
+func (b *bgpRib) enrichSide(flow *public.Flow, dir string) {
+	ip := flow.AsIp(dir + "_ip")
+	if ip == nil {
+		return
+	}
+	b.mu.RLock()
+	r, _, ok := b.routes.lookup(ip)
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+	flow.AddAttr(dir+"_bgp_prefix", r.prefix)
+	flow.AddAttr(dir+"_bgp_origin_as", r.originAs)
+	flow.AddAttr(dir+"_bgp_peer_as", r.peerAs)
+	flow.AddAttr(dir+"_bgp_as_path", r.asPath)
+	if b.setAs && flow.Raw(dir+"_as") == nil {
+		flow.AddAttr(dir+"_as", r.originAs)
+	}
+}
+
+func (b *bgpRib) Enrich(flow *public.Flow) {
+	b.enrichSide(flow, "source")
+	b.enrichSide(flow, "destination")
+}
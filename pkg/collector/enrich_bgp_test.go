@@ -0,0 +1,171 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func mrtRecord(typ, subtype uint16, body []byte) []byte {
+	b := make([]byte, 12, 12+len(body))
+	binary.BigEndian.PutUint32(b, 1760832000)
+	binary.BigEndian.PutUint16(b[4:], typ)
+	binary.BigEndian.PutUint16(b[6:], subtype)
+	binary.BigEndian.PutUint32(b[8:], uint32(len(body)))
+	return append(b, body...)
+}
+
+// asPathAttr encodes AS_PATH attribute with single AS_SEQUENCE segment, optionally followed by AS_SET
+func asPathAttr(seq []uint32, set []uint32) []byte {
+	var v []byte
+	for _, seg := range []struct {
+		typ byte
+		as  []uint32
+	}{{bgpAsPathSegmentSequence, seq}, {bgpAsPathSegmentSet, set}} {
+		if len(seg.as) == 0 {
+			continue
+		}
+		v = append(v, seg.typ, byte(len(seg.as)))
+		for _, as := range seg.as {
+			v = binary.BigEndian.AppendUint32(v, as)
+		}
+	}
+	// ORIGIN attribute precedes AS_PATH, AS_PATH uses extended length
+	attrs := []byte{0x40, 1, 1, 0}
+	attrs = append(attrs, 0x50, bgpAttrTypeAsPath)
+	attrs = binary.BigEndian.AppendUint16(attrs, uint16(len(v)))
+	return append(attrs, v...)
+}
+
+func ribRecord(prefix string, addPath bool, attrs ...[]byte) []byte {
+	_, n, _ := net.ParseCIDR(prefix)
+	ones, bits := n.Mask.Size()
+	subtype := uint16(mrtSubtypeRibIPv4Unicast)
+	if bits == 128 {
+		subtype = mrtSubtypeRibIPv6Unicast
+	}
+	if addPath {
+		subtype += 6
+	}
+	ip := n.IP.To4()
+	if bits == 128 {
+		ip = n.IP.To16()
+	}
+	b := binary.BigEndian.AppendUint32(nil, 1)
+	b = append(b, byte(ones))
+	b = append(b, ip[:(ones+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	for i, a := range attrs {
+		b = binary.BigEndian.AppendUint16(b, uint16(i))
+		b = binary.BigEndian.AppendUint32(b, 1760800000)
+		if addPath {
+			b = binary.BigEndian.AppendUint32(b, uint32(i+1))
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a)))
+		b = append(b, a...)
+	}
+	return mrtRecord(mrtTypeTableDumpV2, subtype, b)
+}
+
+func testRibDump() []byte {
+	var buf bytes.Buffer
+	// PEER_INDEX_TABLE is not needed and skipped
+	buf.Write(mrtRecord(mrtTypeTableDumpV2, 1, []byte{10, 0, 0, 1, 0, 0, 0, 0}))
+	buf.Write(ribRecord("8.8.8.0/24", false,
+		asPathAttr([]uint32{64500, 3356, 15169}, nil),
+		asPathAttr([]uint32{64501, 15169}, nil),
+	))
+	buf.Write(ribRecord("8.0.0.0/9", false, asPathAttr([]uint32{64500, 3356}, nil)))
+	buf.Write(ribRecord("1.1.1.0/24", true,
+		asPathAttr([]uint32{64502, 13335}, nil),
+		asPathAttr([]uint32{64500}, []uint32{13335, 13336}),
+	))
+	buf.Write(ribRecord("2001:db8::/32", false, asPathAttr([]uint32{64500, 65001}, nil)))
+	// BGP4MP record is skipped
+	buf.Write(mrtRecord(16, 4, []byte{1, 2, 3, 4}))
+	return buf.Bytes()
+}
+
+func TestReadMrtRib(t *testing.T) {
+	var prefixes []string
+	assert.NoError(t, readMrtRib(bytes.NewReader(testRibDump()), func(prefix *net.IPNet, entries []mrtRibEntry) {
+		prefixes = append(prefixes, prefix.String())
+		if prefix.String() == "1.1.1.0/24" {
+			assert.Equal(t, 2, len(entries))
+			assert.Equal(t, []uint32{64500, 13335, 13336}, entries[1].asPath)
+			assert.Equal(t, 2, entries[1].pathLen)
+		}
+	}))
+	assert.Equal(t, []string{"8.8.8.0/24", "8.0.0.0/9", "1.1.1.0/24", "2001:db8::/32"}, prefixes)
+
+	dump := testRibDump()
+	assert.ErrorIs(t, readMrtRib(bytes.NewReader(dump[:len(dump)-2]), func(*net.IPNet, []mrtRibEntry) {}), errMrtTruncated)
+	// length in header is not trusted
+	assert.ErrorContains(t, readMrtRib(bytes.NewReader([]byte{0, 0, 0, 0, 0, 13, 0, 2, 0xff, 0xff, 0xff, 0xff}),
+		func(*net.IPNet, []mrtRibEntry) {}), "too long")
+}
+
+func TestBgpRib(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write(testRibDump())
+	assert.NoError(t, gw.Close())
+	file := filepath.Join(t.TempDir(), "rib.20261019.0000.gz")
+	assert.NoError(t, os.WriteFile(file, buf.Bytes(), 0o600))
+
+	e := getEnricher("bgp_rib")
+	e.Configure(map[string]interface{}{
+		"file":   file,
+		"set_as": true,
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 10})
+	f.AddAttr("destination_ip", []byte{8, 8, 8, 8})
+	f.AddAttr("source_as", uint32(64512))
+	e.Enrich(f)
+	// shortest path is selected
+	assert.Equal(t, "8.8.8.0/24", *f.AsString("destination_bgp_prefix"))
+	assert.Equal(t, uint32(15169), *f.AsUint32("destination_bgp_origin_as"))
+	assert.Equal(t, uint32(64501), *f.AsUint32("destination_bgp_peer_as"))
+	assert.Equal(t, "64501 15169", *f.AsString("destination_bgp_as_path"))
+	assert.Equal(t, uint32(15169), *f.AsUint32("destination_as"))
+	assert.Nil(t, f.AsString("source_bgp_prefix"))
+	assert.Equal(t, uint32(64512), *f.AsUint32("source_as"))
+
+	f = &public.Flow{}
+	f.AddAttr("source_ip", []byte{8, 9, 0, 1})
+	f.AddAttr("destination_ip", []byte{1, 1, 1, 1})
+	e.Enrich(f)
+	assert.Equal(t, "8.0.0.0/9", *f.AsString("source_bgp_prefix"))
+	assert.Equal(t, uint32(3356), *f.AsUint32("source_bgp_origin_as"))
+	// AS_SET counts as single hop
+	assert.Equal(t, "64502 13335", *f.AsString("destination_bgp_as_path"))
+
+	assert.Error(t, (&bgpRib{}).Start())
+}
@@ -0,0 +1,306 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+type cloudRange struct {
+	prefix  *net.IPNet
+	cloud   string
+	region  string
+	service string
+	// generic ranges (such as AWS "AMAZON") are overridden by more specific entries for the same prefix
+	generic bool
+}
+
+type cloudSource struct {
+	cloud  string
+	format string
+	file   string
+}
+
+// cloudProvider tags flows with cloud provider, region and service based on published IP ranges.
+type cloudProvider struct {
+	logger         *slog.Logger
+	sources        []cloudSource
+	reloadInterval time.Duration
+
+	mu     sync.RWMutex
+	ranges *prefixTrie[*cloudRange]
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+var cloudRangeParsers = map[string]func(io.Reader, string) ([]*cloudRange, error){
+	"aws":   parseAwsRanges,
+	"gcp":   parseGcpRanges,
+	"azure": parseAzureRanges,
+	"plain": parsePlainRanges,
+}
+
+func (c *cloudProvider) Configure(cfg map[string]interface{}) {
+	c.reloadInterval = cfgPositiveDuration(cfg, "reload_interval", time.Hour)
+	c.sources = nil
+	if s, ok := cfg["sources"]; ok {
+		list, ok := s.([]interface{})
+		if !ok {
+			panic("sources (if specified) must be a list")
+		}
+		for _, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				panic("source must be a mapping with provider and file keys")
+			}
+			src := cloudSource{
+				cloud: cfgString(m, "provider", ""),
+				file:  cfgString(m, "file", ""),
+			}
+			if len(src.cloud) == 0 || len(src.file) == 0 {
+				panic("source must have both provider and file specified")
+			}
+			defFormat := "plain"
+			if _, known := cloudRangeParsers[src.cloud]; known {
+				defFormat = src.cloud
+			}
+			src.format = cfgString(m, "format", defFormat)
+			if _, ok = cloudRangeParsers[src.format]; !ok {
+				panic(fmt.Sprintf("unsupported format of cloud ranges: %s", src.format))
+			}
+			c.sources = append(c.sources, src)
+		}
+	}
+}
+
+func (c *cloudProvider) Start() error {
+	c.logger = baseLogger.With("component", "cloud_provider")
+	if err := c.reload(); err != nil {
+		return err
+	}
+	c.stopCh = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopCh:
+				return
+			case <-ticker.C:
+				if err := c.reload(); err != nil {
+					c.logger.Warn("unable to reload cloud ranges, keeping previous data", "err", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *cloudProvider) Close() error {
+	if c.stopCh != nil {
+		close(c.stopCh)
+		c.wg.Wait()
+		c.stopCh = nil
+	}
+	return nil
+}
+
+func (c *cloudProvider) loadSource(src cloudSource) ([]*cloudRange, error) {
+	f, err := os.Open(src.file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return cloudRangeParsers[src.format](f, src.cloud)
+}
+
+// reload loads all sources into new trie, which then replaces current one.
+func (c *cloudProvider) reload() error {
+	var all []*cloudRange
+	for _, src := range c.sources {
+		ranges, err := c.loadSource(src)
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", src.cloud, src.file, err)
+		}
+		all = append(all, ranges...)
+	}
+	// generic entries go first, so that specific ones replace them
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].generic && !all[j].generic
+	})
+	t := newPrefixTrie[*cloudRange]()
+	for _, cr := range all {
+		t.insert(cr.prefix, cr)
+	}
+	c.mu.Lock()
+	c.ranges = t
+	c.mu.Unlock()
+	c.logger.Debug("cloud ranges loaded", "prefixes", t.len())
+	return nil
+}
+
+func parseAwsRanges(r io.Reader, cloud string) ([]*cloudRange, error) {
+	type awsPrefix struct {
+		IPv4    string `json:"ip_prefix"`
+		IPv6    string `json:"ipv6_prefix"`
+		Region  string `json:"region"`
+		Service string `json:"service"`
+	}
+	var doc struct {
+		Prefixes     []awsPrefix `json:"prefixes"`
+		IPv6Prefixes []awsPrefix `json:"ipv6_prefixes"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var res []*cloudRange
+	for _, p := range append(doc.Prefixes, doc.IPv6Prefixes...) {
+		_, n, err := net.ParseCIDR(p.IPv4 + p.IPv6)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &cloudRange{
+			prefix:  n,
+			cloud:   cloud,
+			region:  p.Region,
+			service: p.Service,
+			generic: p.Service == "AMAZON",
+		})
+	}
+	return res, nil
+}
+
+func parseGcpRanges(r io.Reader, cloud string) ([]*cloudRange, error) {
+	var doc struct {
+		Prefixes []struct {
+			IPv4    string `json:"ipv4Prefix"`
+			IPv6    string `json:"ipv6Prefix"`
+			Service string `json:"service"`
+			Scope   string `json:"scope"`
+		} `json:"prefixes"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var res []*cloudRange
+	for _, p := range doc.Prefixes {
+		_, n, err := net.ParseCIDR(p.IPv4 + p.IPv6)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &cloudRange{
+			prefix:  n,
+			cloud:   cloud,
+			region:  p.Scope,
+			service: p.Service,
+		})
+	}
+	return res, nil
+}
+
+// parseAzureRanges parses Azure service tags file (ServiceTags_Public_*.json)
+func parseAzureRanges(r io.Reader, cloud string) ([]*cloudRange, error) {
+	var doc struct {
+		Values []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var res []*cloudRange
+	for _, v := range doc.Values {
+		service := v.Properties.SystemService
+		if len(service) == 0 {
+			// e.g. "AzureCloud.eastus"
+			service, _, _ = strings.Cut(v.Name, ".")
+		}
+		for _, p := range v.Properties.AddressPrefixes {
+			_, n, err := net.ParseCIDR(p)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, &cloudRange{
+				prefix:  n,
+				cloud:   cloud,
+				region:  v.Properties.Region,
+				service: service,
+				generic: len(v.Properties.SystemService) == 0,
+			})
+		}
+	}
+	return res, nil
+}
+
+// parsePlainRanges parses list of prefixes, one per line (e.g. Cloudflare's ips-v4 and ips-v6)
+func parsePlainRanges(r io.Reader, cloud string) ([]*cloudRange, error) {
+	var res []*cloudRange
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		_, n, err := net.ParseCIDR(line)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &cloudRange{prefix: n, cloud: cloud})
+	}
+	return res, scanner.Err()
+}
+
+func (c *cloudProvider) enrichSide(flow *public.Flow, dir string) {
+	ip := flow.AsIp(dir + "_ip")
+	if ip == nil {
+		return
+	}
+	c.mu.RLock()
+	cr, _, ok := c.ranges.lookup(ip)
+	c.mu.RUnlock()
+	if !ok {
+		return
+	}
+	flow.AddAttr(dir+"_cloud", cr.cloud)
+	flow.AddAttr(dir+"_cloud_region", cr.region)
+	flow.AddAttr(dir+"_cloud_service", cr.service)
+}
+
+func (c *cloudProvider) Enrich(flow *public.Flow) {
+	c.enrichSide(flow, "source")
+	c.enrichSide(flow, "destination")
+}
@@ -0,0 +1,111 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudProvider(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		return p
+	}
+	aws := write("ip-ranges.json", `{
+  "syncToken": "1760000000",
+  "prefixes": [
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "EC2", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "3.0.0.0/9", "region": "GLOBAL", "service": "AMAZON", "network_border_group": "GLOBAL"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2", "service": "EC2", "network_border_group": "us-west-2"}
+  ]
+}`)
+	gcp := write("cloud.json", `{
+  "prefixes": [
+    {"ipv4Prefix": "34.1.208.0/20", "service": "Google Cloud", "scope": "africa-south1"}
+  ]
+}`)
+	azure := write("ServiceTags_Public.json", `{
+  "values": [
+    {"name": "AzureCloud.eastus", "properties": {"region": "eastus", "systemService": "", "addressPrefixes": ["20.42.0.0/17"]}},
+    {"name": "Storage.EastUS", "properties": {"region": "eastus", "systemService": "AzureStorage", "addressPrefixes": ["20.42.0.0/17"]}}
+  ]
+}`)
+	cloudflare := write("ips-v4", "173.245.48.0/20\n104.16.0.0/13\n")
+
+	e := getEnricher("cloud_provider")
+	e.Configure(map[string]interface{}{
+		"sources": []interface{}{
+			map[string]interface{}{"provider": "aws", "file": aws},
+			map[string]interface{}{"provider": "gcp", "file": gcp},
+			map[string]interface{}{"provider": "azure", "file": azure},
+			map[string]interface{}{"provider": "cloudflare", "file": cloudflare},
+		},
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	check := func(ip []byte, cloud, region, service string) {
+		f := &public.Flow{}
+		f.AddAttr("destination_ip", ip)
+		e.Enrich(f)
+		assert.Equal(t, cloud, *f.AsString("destination_cloud"))
+		assert.Equal(t, region, *f.AsString("destination_cloud_region"))
+		assert.Equal(t, service, *f.AsString("destination_cloud_service"))
+	}
+	check([]byte{3, 5, 141, 1}, "aws", "ap-northeast-2", "EC2")
+	check([]byte{3, 6, 0, 1}, "aws", "GLOBAL", "AMAZON")
+	check([]byte{34, 1, 210, 1}, "gcp", "africa-south1", "Google Cloud")
+	check([]byte{20, 42, 1, 1}, "azure", "eastus", "AzureStorage")
+	check([]byte{104, 17, 0, 1}, "cloudflare", "", "")
+
+	f := &public.Flow{}
+	f.AddAttr("destination_ip", []byte{192, 168, 0, 1})
+	e.Enrich(f)
+	assert.Nil(t, f.AsString("destination_cloud"))
+
+	// failed reload keeps previous data
+	assert.NoError(t, os.WriteFile(gcp, []byte("{"), 0o600))
+	assert.Error(t, e.(*cloudProvider).reload())
+	check([]byte{34, 1, 210, 1}, "gcp", "africa-south1", "Google Cloud")
+}
+
+func TestCloudProviderInvalidConfig(t *testing.T) {
+	e := &cloudProvider{}
+	assert.Panics(t, func() {
+		e.Configure(map[string]interface{}{
+			"sources": []interface{}{
+				map[string]interface{}{"provider": "oracle", "file": "x.json", "format": "oci"},
+			},
+		})
+	})
+	e.Configure(map[string]interface{}{
+		"sources": []interface{}{
+			map[string]interface{}{"provider": "aws", "file": "/nonexistent/ip-ranges.json"},
+		},
+	})
+	assert.Error(t, e.Start())
+}
@@ -0,0 +1,117 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+const (
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+	protoSCTP   = 132
+)
+
+// ICMP message types which form request/response pairs, treated as ports of two-way conversation
+var (
+	icmpPeerTypes = map[uint32]uint32{
+		8: 0, 0: 8, 13: 14, 14: 13, 15: 16, 16: 15, 10: 9, 9: 10, 17: 18, 18: 17,
+	}
+	icmpv6PeerTypes = map[uint32]uint32{
+		128: 129, 129: 128, 133: 134, 134: 133, 135: 136, 136: 135, 130: 131, 131: 130, 139: 140, 140: 139,
+		144: 145, 145: 144,
+	}
+)
+
+// communityId computes Community ID v1 flow hash (https://github.com/corelight/community-id-spec),
+// as used by Zeek and Suricata.
+type communityId struct {
+	seed uint16
+}
+
+func (c *communityId) Close() error { return nil }
+func (c *communityId) Start() error { return nil }
+
+func (c *communityId) Configure(cfg map[string]interface{}) {
+	seed := cfgInt(cfg, "seed", 0)
+	if seed < 0 || seed > 0xffff {
+		panic("seed (if specified) must be an integer between 0 and 65535")
+	}
+	c.seed = uint16(seed)
+}
+
+func uint32Attr(flow *public.Flow, attr string) uint32 {
+	if v := flow.AsUint32(attr); v != nil {
+		return *v
+	}
+	return 0
+}
+
+// hash computes Community ID for given endpoints. Ports are only used for protocols which have them,
+// for ICMP these are type and code (or type of peer message).
+func (c *communityId) hash(srcIp, dstIp []byte, proto, srcPort, dstPort uint32) string {
+	oneWay := false
+	switch proto {
+	case protoICMP, protoICMPv6:
+		peers := icmpPeerTypes
+		if proto == protoICMPv6 {
+			peers = icmpv6PeerTypes
+		}
+		if peer, ok := peers[srcPort]; ok {
+			dstPort = peer
+		} else {
+			oneWay = true
+		}
+	}
+	if !oneWay {
+		if cmp := bytes.Compare(srcIp, dstIp); cmp > 0 || (cmp == 0 && srcPort > dstPort) {
+			srcIp, dstIp = dstIp, srcIp
+			srcPort, dstPort = dstPort, srcPort
+		}
+	}
+	buf := make([]byte, 0, 2+2*16+2+4)
+	buf = binary.BigEndian.AppendUint16(buf, c.seed)
+	buf = append(buf, srcIp...)
+	buf = append(buf, dstIp...)
+	buf = append(buf, byte(proto), 0)
+	switch proto {
+	case protoICMP, protoICMPv6, protoTCP, protoUDP, protoSCTP:
+		buf = binary.BigEndian.AppendUint16(buf, uint16(srcPort))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(dstPort))
+	}
+	sum := sha1.Sum(buf)
+	return "1:" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (c *communityId) Enrich(flow *public.Flow) {
+	srcIp, ok1 := flow.Raw("source_ip").([]byte)
+	dstIp, ok2 := flow.Raw("destination_ip").([]byte)
+	if !ok1 || !ok2 || len(srcIp) != len(dstIp) {
+		return
+	}
+	proto := uint32Attr(flow, "proto")
+	srcPort, dstPort := uint32Attr(flow, "source_port"), uint32Attr(flow, "destination_port")
+	if proto == protoICMP || proto == protoICMPv6 {
+		srcPort, dstPort = uint32Attr(flow, "icmp_type"), uint32Attr(flow, "icmp_code")
+	}
+	flow.AddAttr("community_id", c.hash(srcIp, dstIp, proto, srcPort, dstPort))
+}
@@ -0,0 +1,120 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func communityIdFlow(src, dst string, proto, srcPort, dstPort uint32) *public.Flow {
+	f := &public.Flow{}
+	srcIp, dstIp := net.ParseIP(src), net.ParseIP(dst)
+	if srcIp.To4() != nil {
+		srcIp, dstIp = srcIp.To4(), dstIp.To4()
+	}
+	f.AddAttr("source_ip", []byte(srcIp))
+	f.AddAttr("destination_ip", []byte(dstIp))
+	f.AddAttr("proto", proto)
+	if proto == protoICMP || proto == protoICMPv6 {
+		f.AddAttr("icmp_type", srcPort)
+		f.AddAttr("icmp_code", dstPort)
+	} else {
+		f.AddAttr("source_port", srcPort)
+		f.AddAttr("destination_port", dstPort)
+	}
+	return f
+}
+
+func TestCommunityId(t *testing.T) {
+	e := getEnricher("community_id")
+	e.Configure(map[string]interface{}{})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	// test vectors from community-id-spec baseline
+	for _, tc := range []struct {
+		src, dst         string
+		proto            uint32
+		srcPort, dstPort uint32
+		expected         string
+	}{
+		{"128.232.110.120", "66.35.250.204", protoTCP, 34855, 80, "1:LQU9qZlK+B5F3KDmev6m5PMibrg="},
+		{"66.35.250.204", "128.232.110.120", protoTCP, 80, 34855, "1:LQU9qZlK+B5F3KDmev6m5PMibrg="},
+		{"192.168.1.52", "8.8.8.8", protoUDP, 54585, 53, "1:d/FP5EW3wiY1vCndhwleRRKHowQ="},
+		{"8.8.8.8", "192.168.1.52", protoUDP, 53, 54585, "1:d/FP5EW3wiY1vCndhwleRRKHowQ="},
+		{"192.168.0.89", "192.168.0.1", protoICMP, 8, 0, "1:X0snYXpgwiv9TZtqg64sgzUn6Dk="},
+		{"192.168.0.1", "192.168.0.89", protoICMP, 0, 0, "1:X0snYXpgwiv9TZtqg64sgzUn6Dk="},
+		// ICMPv6 node information query and reply
+		{"fe80::200:86ff:fe05:80da", "fe80::260:97ff:fe07:69ea", protoICMPv6, 139, 0, "1:5M4clpPt0fX67/4bIqQT4gA26Dw="},
+		{"fe80::260:97ff:fe07:69ea", "fe80::200:86ff:fe05:80da", protoICMPv6, 140, 0, "1:5M4clpPt0fX67/4bIqQT4gA26Dw="},
+	} {
+		f := communityIdFlow(tc.src, tc.dst, tc.proto, tc.srcPort, tc.dstPort)
+		e.Enrich(f)
+		assert.Equal(t, tc.expected, *f.AsString("community_id"), "%s -> %s", tc.src, tc.dst)
+	}
+
+	// seed changes hash
+	e.Configure(map[string]interface{}{"seed": 1})
+	f := communityIdFlow("128.232.110.120", "66.35.250.204", protoTCP, 34855, 80)
+	e.Enrich(f)
+	assert.NotEqual(t, "1:LQU9qZlK+B5F3KDmev6m5PMibrg=", *f.AsString("community_id"))
+
+	assert.Panics(t, func() {
+		e.Configure(map[string]interface{}{"seed": 70000})
+	})
+}
+
+type capturingConsumer struct {
+	msgs []*flowpb.FlowMessage
+}
+
+func (c *capturingConsumer) Publish(msgs []*flowpb.FlowMessage) {
+	c.msgs = append(c.msgs, msgs...)
+}
+
+func TestCommunityIdNetFlowV5Icmp(t *testing.T) {
+	e := getEnricher("community_id")
+	assert.NoError(t, e.Start())
+
+	// echo request 192.168.0.89 -> 192.168.0.1, type and code are in destination port
+	pkt := netflowV5Packet(0, 1)
+	rec := pkt[24:]
+	copy(rec[0:], []byte{192, 168, 0, 89})
+	copy(rec[4:], []byte{192, 168, 0, 1})
+	binary.BigEndian.PutUint16(rec[34:], 8<<8|0)
+	rec[38] = protoICMP
+
+	consumer := &capturingConsumer{}
+	pipe := utils.NewFlowPipe(&utils.PipeConfig{
+		Producer: &producerMetricAdapter{consumer: consumer},
+	})
+	assert.NoError(t, pipe.DecodeFlow(exportMessage("10.0.0.1:5000", pkt)))
+	assert.Equal(t, 1, len(consumer.msgs))
+
+	f := (&col{}).mapMsg(consumer.msgs[0])
+	assert.Equal(t, uint32(8), *f.AsUint32("icmp_type"))
+	assert.Equal(t, uint32(0), *f.AsUint32("icmp_code"))
+	e.Enrich(f)
+	assert.Equal(t, "1:X0snYXpgwiv9TZtqg64sgzUn6Dk=", *f.AsString("community_id"))
+}
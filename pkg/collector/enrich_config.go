@@ -0,0 +1,117 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+// helpers to read typed values from enricher configuration, they panic on wrong type,
+// same way as other enrichers do. Collector reports such panic as configuration error of enricher.
+// Enrichers implementing public.EnricherV2 turn it into returned error using cfgRecover.
+
+// cfgRecover converts panic raised by configuration helpers into error, it must be deferred
+func cfgRecover(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("%v", r)
+		}
+	}
+}
+
+func cfgString(cfg map[string]interface{}, key string, def string) string {
+	v, ok := cfg[key]
+	if !ok {
+		return def
+	}
+	s, ok := v.(string)
+	if !ok {
+		panic(fmt.Sprintf("%s (if specified) must be a string", key))
+	}
+	return s
+}
+
+func cfgBool(cfg map[string]interface{}, key string, def bool) bool {
+	v, ok := cfg[key]
+	if !ok {
+		return def
+	}
+	b, ok := v.(bool)
+	if !ok {
+		panic(fmt.Sprintf("%s (if specified) must be a boolean, e.g. true (or false)", key))
+	}
+	return b
+}
+
+func cfgInt(cfg map[string]interface{}, key string, def int) int {
+	v, ok := cfg[key]
+	if !ok {
+		return def
+	}
+	i, ok := v.(int)
+	if !ok {
+		panic(fmt.Sprintf("%s (if specified) must be an integer", key))
+	}
+	return i
+}
+
+func cfgDuration(cfg map[string]interface{}, key string, def time.Duration) time.Duration {
+	v, ok := cfg[key]
+	if !ok {
+		return def
+	}
+	s, ok := v.(string)
+	if !ok {
+		panic(fmt.Sprintf("%s (if specified) must be a Go duration string, e.g. 1h", key))
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		panic(fmt.Sprintf("%s (if specified) must be a Go duration string, e.g. 1h", key))
+	}
+	return d
+}
+
+// cfgPositiveDuration is same as cfgDuration, but it rejects zero and negative durations,
+// which would otherwise make time.NewTicker panic or every operation time out immediately.
+func cfgPositiveDuration(cfg map[string]interface{}, key string, def time.Duration) time.Duration {
+	d := cfgDuration(cfg, key, def)
+	if d <= 0 {
+		panic(fmt.Sprintf("%s (if specified) must be a positive Go duration string, e.g. 1h", key))
+	}
+	return d
+}
+
+func cfgStringSlice(cfg map[string]interface{}, key string) []string {
+	v, ok := cfg[key]
+	if !ok {
+		return nil
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		panic(fmt.Sprintf("%s (if specified) must be a list of strings", key))
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			panic(fmt.Sprintf("%s (if specified) must be a list of strings", key))
+		}
+		out = append(out, s)
+	}
+	return out
+}
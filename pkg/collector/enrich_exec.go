@@ -0,0 +1,391 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+var (
+	errExecNotRunning = errors.New("external process is not running")
+	errExecTimeout    = errors.New("external process did not answer in time")
+	errExecExited     = errors.New("external process exited")
+)
+
+type execRequest struct {
+	id    uint64
+	attrs map[string]interface{}
+	// receives outcome of request once batch is processed
+	done chan execResult
+}
+
+type execResult struct {
+	// attributes returned by process, nil on failure
+	attrs map[string]interface{}
+	err   error
+}
+
+type execRequestLine struct {
+	Id   uint64                 `json:"id"`
+	Flow map[string]interface{} `json:"flow"`
+}
+
+type execResponseLine struct {
+	Id    uint64                 `json:"id"`
+	Attrs map[string]interface{} `json:"attrs"`
+}
+
+// execProcess is single run of external process
+type execProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	w     *bufio.Writer
+	// stdout lines, closed when process exits
+	lines chan []byte
+}
+
+// execEnricher passes flows to external process as JSON lines and merges attributes it returns.
+// All flows of single packet are queued at once, flows that are queued at the same time are grouped into batches.
+type execEnricher struct {
+	logger       *slog.Logger
+	command      []string
+	batchSize    int
+	batchDelay   time.Duration
+	timeout      time.Duration
+	restartDelay time.Duration
+
+	reqCh    chan []*execRequest
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	nextId   uint64
+	proc     *execProcess
+	// process is not started again before this time
+	restartAt time.Time
+
+	batchDuration prometheus.Histogram
+	restarts      prometheus.Counter
+}
+
+func (e *execEnricher) Configure(cfg map[string]interface{}) (err error) {
+	defer cfgRecover(&err)
+	e.command = cfgStringSlice(cfg, "command")
+	e.batchSize = cfgInt(cfg, "batch_size", 100)
+	e.batchDelay = cfgDuration(cfg, "batch_delay", 0)
+	e.timeout = cfgPositiveDuration(cfg, "timeout", time.Second)
+	e.restartDelay = cfgDuration(cfg, "restart_delay", time.Second)
+	if e.batchSize < 1 {
+		return errors.New("batch_size (if specified) must be a positive integer")
+	}
+	return nil
+}
+
+func (e *execEnricher) Start(context.Context) error {
+	e.logger = baseLogger.With("component", "exec")
+	if len(e.command) == 0 {
+		return fmt.Errorf("command must be specified")
+	}
+	e.batchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem: "exec",
+		Name:      "batch_duration_seconds",
+		Help:      "Time taken by external process to process batch of flows.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+	e.restarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "exec",
+		Name:      "restarts",
+		Help:      "The total number of times external process was (re)started.",
+	})
+	e.reqCh = make(chan []*execRequest, 1)
+	e.stopCh = make(chan struct{})
+	if err := e.startProcess(); err != nil {
+		return err
+	}
+	e.wg.Add(1)
+	go e.dispatch()
+	return nil
+}
+
+func (e *execEnricher) Close() error {
+	if e.stopCh != nil {
+		e.stopOnce.Do(func() {
+			close(e.stopCh)
+			e.wg.Wait()
+			e.stopProcess()
+		})
+	}
+	return nil
+}
+
+func (e *execEnricher) Describe(ch chan<- *prometheus.Desc) {
+	e.batchDuration.Describe(ch)
+	e.restarts.Describe(ch)
+}
+
+func (e *execEnricher) Collect(ch chan<- prometheus.Metric) {
+	e.batchDuration.Collect(ch)
+	e.restarts.Collect(ch)
+}
+
+func (e *execEnricher) startProcess() error {
+	cmd := exec.Command(e.command[0], e.command[1:]...)
+	setProcessGroup(cmd)
+	// descendants of process may keep its output open, so don't wait for them forever once it exits
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	e.restarts.Inc()
+	p := &execProcess{
+		cmd:   cmd,
+		stdin: stdin,
+		w:     bufio.NewWriter(stdin),
+		lines: make(chan []byte, e.batchSize),
+	}
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			e.logger.Warn("process stderr", "line", scanner.Text())
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			p.lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+	go func() {
+		// Wait must not be called before all output is read
+		readers.Wait()
+		err := cmd.Wait()
+		e.logger.Info("process exited", "pid", cmd.Process.Pid, "err", err)
+		close(p.lines)
+	}()
+	e.logger.Info("process started", "command", e.command, "pid", cmd.Process.Pid)
+	e.proc = p
+	return nil
+}
+
+func (e *execEnricher) stopProcess() {
+	if e.proc == nil {
+		return
+	}
+	_ = e.proc.stdin.Close()
+	killProcessGroup(e.proc.cmd)
+	// drain output until process is reaped
+	for range e.proc.lines {
+	}
+	e.proc = nil
+}
+
+// fail terminates process after error, it will be restarted with next batch after delay.
+// Error is returned, so that it can be reported for every unanswered flow of batch.
+func (e *execEnricher) fail(err error) error {
+	e.logger.Warn("external process failed", "err", err)
+	e.stopProcess()
+	e.restartAt = time.Now().Add(e.restartDelay)
+	return err
+}
+
+func (e *execEnricher) dispatch() {
+	defer e.wg.Done()
+	var queued []*execRequest
+	for {
+		if len(queued) == 0 {
+			select {
+			case <-e.stopCh:
+				return
+			case reqs := <-e.reqCh:
+				queued = append(queued, reqs...)
+			}
+		}
+		// callers block until their flows are processed, so batch is sent as soon as nothing more is queued,
+		// unless configured to wait for more flows
+		var linger *time.Timer
+	collect:
+		for len(queued) < e.batchSize {
+			select {
+			case reqs := <-e.reqCh:
+				queued = append(queued, reqs...)
+				continue
+			default:
+			}
+			if e.batchDelay == 0 {
+				break collect
+			}
+			if linger == nil {
+				linger = time.NewTimer(e.batchDelay)
+			}
+			select {
+			case reqs := <-e.reqCh:
+				queued = append(queued, reqs...)
+			case <-linger.C:
+				break collect
+			case <-e.stopCh:
+				break collect
+			}
+		}
+		if linger != nil {
+			linger.Stop()
+		}
+		n := min(len(queued), e.batchSize)
+		e.process(queued[:n])
+		queued = queued[n:]
+	}
+}
+
+func (e *execEnricher) process(batch []*execRequest) {
+	results := make(map[uint64]map[string]interface{}, len(batch))
+	pending := make(map[uint64]bool, len(batch))
+	for _, req := range batch {
+		e.nextId++
+		req.id = e.nextId
+		pending[req.id] = true
+	}
+	// error of whole batch, reported for flows that were not answered
+	var batchErr error
+	defer func() {
+		for _, req := range batch {
+			if pending[req.id] {
+				req.done <- execResult{err: batchErr}
+			} else {
+				req.done <- execResult{attrs: results[req.id]}
+			}
+		}
+	}()
+	if e.proc == nil {
+		if time.Now().Before(e.restartAt) {
+			batchErr = errExecNotRunning
+			return
+		}
+		if err := e.startProcess(); err != nil {
+			batchErr = e.fail(fmt.Errorf("unable to start external process: %w", err))
+			return
+		}
+	}
+	started := time.Now()
+	timeout := time.NewTimer(e.timeout)
+	defer timeout.Stop()
+
+	written := make(chan error, 1)
+	go func(p *execProcess) {
+		for _, req := range batch {
+			line, err := json.Marshal(&execRequestLine{Id: req.id, Flow: req.attrs})
+			if err != nil {
+				written <- err
+				return
+			}
+			_, _ = p.w.Write(line)
+			_ = p.w.WriteByte('\n')
+		}
+		written <- p.w.Flush()
+	}(e.proc)
+	select {
+	case err := <-written:
+		if err != nil {
+			batchErr = e.fail(fmt.Errorf("unable to write to external process: %w", err))
+			return
+		}
+	case <-timeout.C:
+		batchErr = e.fail(errExecTimeout)
+		// unblock writer, so that it does not leak
+		<-written
+		return
+	}
+	for len(pending) > 0 {
+		select {
+		case line, ok := <-e.proc.lines:
+			if !ok {
+				batchErr = e.fail(errExecExited)
+				return
+			}
+			var resp execResponseLine
+			if err := json.Unmarshal(line, &resp); err != nil || !pending[resp.Id] {
+				// flow that misses answer because of this fails with timeout
+				e.logger.Warn("invalid response of external process", "line", string(line), "err", err)
+				continue
+			}
+			delete(pending, resp.Id)
+			results[resp.Id] = resp.Attrs
+		case <-timeout.C:
+			batchErr = e.fail(errExecTimeout)
+			return
+		}
+	}
+	e.batchDuration.Observe(time.Since(started).Seconds())
+}
+
+func (e *execEnricher) Enrich(flow *public.Flow) error {
+	return e.EnrichBatch([]*public.Flow{flow})[0]
+}
+
+// EnrichBatch queues all flows at once, so that they can be sent to process in single batch
+func (e *execEnricher) EnrichBatch(flows []*public.Flow) []error {
+	errs := make([]error, len(flows))
+	reqs := make([]*execRequest, len(flows))
+	for i, flow := range flows {
+		reqs[i] = &execRequest{
+			attrs: encodeFlowAttrs(flow),
+			done:  make(chan execResult, 1),
+		}
+	}
+	select {
+	case e.reqCh <- reqs:
+	case <-e.stopCh:
+		return errs
+	}
+	for i, req := range reqs {
+		select {
+		case res := <-req.done:
+			if res.err != nil {
+				errs[i] = res.err
+			} else {
+				errs[i] = mergeFlowAttrs(flows[i], res.attrs)
+			}
+		case <-e.stopCh:
+			return errs
+		}
+	}
+	return errs
+}
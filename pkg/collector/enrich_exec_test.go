@@ -0,0 +1,203 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExecHelperProcess is not a real test, it is started as external process by exec enricher tests.
+func TestExecHelperProcess(t *testing.T) {
+	mode := os.Getenv("EXEC_HELPER_MODE")
+	if len(mode) == 0 {
+		return
+	}
+	if mode == "fork" {
+		// descendant keeps stdout open after process is killed
+		cmd := exec.Command("sleep", "3600")
+		cmd.Stdout = os.Stdout
+		_ = cmd.Start()
+		select {}
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req execRequestLine
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		switch mode {
+		case "hang":
+			select {}
+		case "exit":
+			// terminate on first request, so that enricher has to restart
+			os.Exit(1)
+		}
+		out, _ := json.Marshal(&execResponseLine{Id: req.Id, Attrs: map[string]interface{}{
+			"owner":       "team-" + req.Flow["source_ip"].(string),
+			"port_sum":    req.Flow["source_port"].(float64) + req.Flow["destination_port"].(float64),
+			"score":       0.5,
+			"bytes":       "not a number",
+			"unsupported": []int{1},
+		}})
+		fmt.Println(string(out))
+	}
+	os.Exit(0)
+}
+
+func execHelper(t *testing.T, mode string, cfg map[string]interface{}) *execEnricher {
+	t.Setenv("EXEC_HELPER_MODE", mode)
+	cfg["command"] = []interface{}{os.Args[0], "-test.run=^TestExecHelperProcess$"}
+	_, impl := newEnricher("exec")
+	e := impl.(*execEnricher)
+	assert.NoError(t, e.Configure(cfg))
+	assert.NoError(t, e.Start(context.Background()))
+	t.Cleanup(func() {
+		_ = e.Close()
+	})
+	return e
+}
+
+func execFlow() *public.Flow {
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 10})
+	f.AddAttr("source_port", uint32(40000))
+	f.AddAttr("destination_port", uint32(443))
+	f.AddAttr("bytes", uint64(1000))
+	return f
+}
+
+func TestExecEnricher(t *testing.T) {
+	e := execHelper(t, "echo", map[string]interface{}{
+		"batch_size":  5,
+		"batch_delay": "20ms",
+	})
+	var wg sync.WaitGroup
+	flows := make([]*public.Flow, 12)
+	for i := range flows {
+		flows[i] = execFlow()
+		wg.Add(1)
+		go func(f *public.Flow) {
+			defer wg.Done()
+			assert.EqualError(t, e.Enrich(f), "attributes rejected because of their type: bytes, unsupported")
+		}(flows[i])
+	}
+	wg.Wait()
+	for _, f := range flows {
+		assert.Equal(t, "team-192.168.0.10", *f.AsString("owner"))
+		assert.Equal(t, uint32(40443), *f.AsUint32("port_sum"))
+		assert.Equal(t, 0.5, f.Raw("score"))
+		// built-in attribute can't be replaced by value of other type
+		assert.Equal(t, uint64(1000), f.Raw("bytes"))
+		assert.Nil(t, f.Raw("unsupported"))
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.restarts))
+	// 12 flows do not fit into less than 3 batches of 5
+	m := &dto.Metric{}
+	assert.NoError(t, e.batchDuration.Write(m))
+	assert.GreaterOrEqual(t, m.Histogram.GetSampleCount(), uint64(3))
+}
+
+func TestExecEnricherSequential(t *testing.T) {
+	// batch_delay is not set, so single caller does not wait for flows that will never come
+	e := execHelper(t, "echo", map[string]interface{}{
+		"batch_size": 100,
+	})
+	for i := 0; i < 20; i++ {
+		f := execFlow()
+		assert.Error(t, e.Enrich(f))
+		assert.Equal(t, "team-192.168.0.10", *f.AsString("owner"))
+	}
+	m := &dto.Metric{}
+	assert.NoError(t, e.batchDuration.Write(m))
+	assert.Equal(t, uint64(20), m.Histogram.GetSampleCount())
+}
+
+func TestExecEnricherBatch(t *testing.T) {
+	e := execHelper(t, "echo", map[string]interface{}{
+		"batch_size": 100,
+	})
+	flows := make([]*public.Flow, 250)
+	for i := range flows {
+		flows[i] = execFlow()
+	}
+	errs := e.EnrichBatch(flows)
+	assert.Len(t, errs, len(flows))
+	for i, f := range flows {
+		assert.Error(t, errs[i])
+		assert.Equal(t, "team-192.168.0.10", *f.AsString("owner"))
+	}
+	// flows of single call are sent in as few batches as possible
+	m := &dto.Metric{}
+	assert.NoError(t, e.batchDuration.Write(m))
+	assert.Equal(t, uint64(3), m.Histogram.GetSampleCount())
+}
+
+func TestExecEnricherCloseDescendant(t *testing.T) {
+	e := execHelper(t, "fork", map[string]interface{}{})
+	// give process time to start its child
+	time.Sleep(100 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		_ = e.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "close did not return")
+	}
+}
+
+func TestExecEnricherTimeout(t *testing.T) {
+	e := execHelper(t, "hang", map[string]interface{}{
+		"timeout":       "100ms",
+		"restart_delay": "1h",
+	})
+	f := execFlow()
+	assert.ErrorIs(t, e.Enrich(f), errExecTimeout)
+	assert.Nil(t, f.Raw("owner"))
+
+	// process is not restarted until delay passes
+	started := time.Now()
+	assert.ErrorIs(t, e.Enrich(execFlow()), errExecNotRunning)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestExecEnricherRestart(t *testing.T) {
+	e := execHelper(t, "exit", map[string]interface{}{
+		"restart_delay": "1ms",
+	})
+	for i := 0; i < 3; i++ {
+		f := execFlow()
+		assert.ErrorIs(t, e.Enrich(f), errExecExited)
+		assert.Nil(t, f.Raw("owner"))
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, float64(3), testutil.ToFloat64(e.restarts))
+}
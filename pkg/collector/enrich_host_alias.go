@@ -15,17 +15,108 @@
 package collector
 
 import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"gopkg.in/yaml.v3"
 )
 
+// aliasTable holds exact addresses and networks. When same address or network is added more than once,
+// last alias wins.
+type aliasTable struct {
+	exact map[string]string
+	nets  *prefixTrie[string]
+}
+
+func newAliasTable() *aliasTable {
+	return &aliasTable{exact: map[string]string{}, nets: newPrefixTrie[string]()}
+}
+
+func (t *aliasTable) add(key, alias string) error {
+	if strings.Contains(key, "/") {
+		_, n, err := net.ParseCIDR(key)
+		if err != nil {
+			return err
+		}
+		t.nets.insert(n, alias)
+		return nil
+	}
+	ip := net.ParseIP(key)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address: %s", key)
+	}
+	t.exact[ip.String()] = alias
+	return nil
+}
+
+// lookup finds alias of exact address, or of the most specific network containing it
+func (t *aliasTable) lookup(ip net.IP) (string, bool) {
+	if alias, ok := t.exact[ip.String()]; ok {
+		return alias, true
+	}
+	alias, _, ok := t.nets.lookup(ip)
+	return alias, ok
+}
+
 type enrichHostAlias struct {
-	aliases map[string]string
+	logger         *slog.Logger
+	aliases        map[string]string
+	files          []string
+	fallback       string
+	reloadInterval time.Duration
+
+	mu      sync.RWMutex
+	table   *aliasTable
+	modTime map[string]time.Time
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (e *enrichHostAlias) Close() error {
+	if e.stopCh != nil {
+		close(e.stopCh)
+		e.wg.Wait()
+		e.stopCh = nil
+	}
+	return nil
 }
 
-func (e *enrichHostAlias) Close() error { return nil }
-func (e *enrichHostAlias) Start() error { return nil }
+func (e *enrichHostAlias) Start() error {
+	if len(e.files) == 0 {
+		return nil
+	}
+	e.stopCh = make(chan struct{})
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stopCh:
+				return
+			case <-ticker.C:
+				if e.filesChanged() {
+					if err := e.reload(); err != nil {
+						e.logger.Warn("unable to reload aliases", "err", err)
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
 
 func (e *enrichHostAlias) Configure(cfg map[string]interface{}) {
+	e.logger = baseLogger.With("component", "host_alias")
 	e.aliases = map[string]string{}
 	if _, ok := cfg["alias_map"]; ok {
 		m := cfg["alias_map"].(map[string]interface{})
@@ -33,16 +124,144 @@ func (e *enrichHostAlias) Configure(cfg map[string]interface{}) {
 			e.aliases[k] = v.(string)
 		}
 	}
+	e.files = cfgStringSlice(cfg, "alias_files")
+	e.reloadInterval = cfgPositiveDuration(cfg, "reload_interval", 30*time.Second)
+	switch fallback := cfgString(cfg, "fallback", "unknown"); fallback {
+	case "unknown", "ip":
+		e.fallback = fallback
+	case "empty":
+		e.fallback = ""
+	default:
+		panic(fmt.Sprintf("unsupported fallback: %s", fallback))
+	}
+	if err := e.reload(); err != nil {
+		panic(err)
+	}
+}
+
+// filesChanged reports whether any of alias files was modified, created or removed since last load
+func (e *enrichHostAlias) filesChanged() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, path := range e.files {
+		fi, err := os.Stat(path)
+		if err != nil {
+			// aliases of file that was loaded before are dropped
+			if _, loaded := e.modTime[path]; loaded {
+				return true
+			}
+			continue
+		}
+		if !fi.ModTime().Equal(e.modTime[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load builds new alias table from files and inline map. Inline map takes precedence over files,
+// later files take precedence over earlier ones.
+func (e *enrichHostAlias) load() (*aliasTable, error) {
+	t := newAliasTable()
+	for _, path := range e.files {
+		var err error
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = loadYamlAliases(path, t)
+		default:
+			err = loadHostsAliases(path, t)
+		}
+		if err != nil {
+			e.logger.Warn("unable to load aliases", "path", path, "err", err)
+		}
+	}
+	for k, v := range e.aliases {
+		if err := t.add(k, v); err != nil {
+			return nil, fmt.Errorf("alias_map: %w", err)
+		}
+	}
+	return t, nil
+}
+
+func (e *enrichHostAlias) reload() error {
+	modTime := map[string]time.Time{}
+	for _, path := range e.files {
+		if fi, err := os.Stat(path); err == nil {
+			modTime[path] = fi.ModTime()
+		}
+	}
+	t, err := e.load()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.table = t
+	e.modTime = modTime
+	e.mu.Unlock()
+	e.logger.Debug("aliases loaded", "addresses", len(t.exact), "networks", t.nets.len())
+	return nil
+}
+
+// loadHostsAliases loads file in /etc/hosts format, first name of each entry is used as alias
+func loadHostsAliases(path string, t *aliasTable) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if err = t.add(fields[0], fields[1]); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// loadYamlAliases loads file with mapping of IP address or CIDR to alias
+func loadYamlAliases(path string, t *aliasTable) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m := map[string]string{}
+	if err = yaml.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		if err = t.add(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (e *enrichHostAlias) enrichAliasAttr(flow *public.Flow, attr, dest string) {
-	if ip := flow.AsIp(attr); ip != nil {
-		if alias, ok := e.aliases[ip.String()]; ok {
+	ip := flow.AsIp(attr)
+	if ip != nil {
+		e.mu.RLock()
+		alias, ok := e.table.lookup(ip)
+		e.mu.RUnlock()
+		if ok {
 			flow.AddAttr(dest, alias)
 			return
 		}
 	}
-	flow.AddAttr(dest, "unknown")
+	switch {
+	case e.fallback != "ip":
+		flow.AddAttr(dest, e.fallback)
+	case ip != nil:
+		flow.AddAttr(dest, ip.String())
+	default:
+		flow.AddAttr(dest, "unknown")
+	}
 }
 
 func (e *enrichHostAlias) Enrich(flow *public.Flow) {
@@ -15,7 +15,10 @@
 package collector
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/rkosegi/ipfix-collector/pkg/public"
 	"github.com/stretchr/testify/assert"
@@ -43,3 +46,93 @@ func TestEnrichHostAlias(t *testing.T) {
 
 	assert.Equal(t, "unknown", *f.AsString("source_host_alias"))
 }
+
+func TestEnrichHostAliasFiles(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts")
+	aliases := filepath.Join(dir, "aliases.yaml")
+	assert.NoError(t, os.WriteFile(hosts, []byte(`# static hosts
+192.168.0.1	router gw	# gateway
+192.168.0.5	nas
+192.168.1.0/24	wifi
+`), 0o600))
+	assert.NoError(t, os.WriteFile(aliases, []byte(`
+192.168.0.5: storage
+10.0.0.0/8: datacenter
+10.1.0.0/16: lab
+172.16.0.0/12: vpn
+192.168.1.0/24: guest
+`), 0o600))
+	e := &enrichHostAlias{}
+	e.Configure(map[string]interface{}{
+		"alias_files": []interface{}{hosts, aliases},
+		"alias_map": map[string]interface{}{
+			"10.1.2.3":      "build-server",
+			"172.16.0.0/12": "office",
+		},
+		"fallback":        "ip",
+		"reload_interval": "10ms",
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	alias := func(ip []byte) string {
+		f := &public.Flow{}
+		f.AddAttr("source_ip", ip)
+		e.Enrich(f)
+		return *f.AsString("source_host_alias")
+	}
+	assert.Equal(t, "router", alias([]byte{192, 168, 0, 1}))
+	// later file takes precedence
+	assert.Equal(t, "storage", alias([]byte{192, 168, 0, 5}))
+	// most specific network wins, exact address wins over network
+	assert.Equal(t, "datacenter", alias([]byte{10, 2, 0, 1}))
+	assert.Equal(t, "lab", alias([]byte{10, 1, 0, 1}))
+	assert.Equal(t, "build-server", alias([]byte{10, 1, 2, 3}))
+	// inline map takes precedence over files, later file over earlier one, also for networks
+	assert.Equal(t, "office", alias([]byte{172, 16, 0, 1}))
+	assert.Equal(t, "guest", alias([]byte{192, 168, 1, 1}))
+	assert.Equal(t, "192.168.0.10", alias([]byte{192, 168, 0, 10}))
+
+	assert.NoError(t, os.WriteFile(hosts, []byte("192.168.0.10 printer\n"), 0o600))
+	// ensure modification time differs on filesystems with coarse timestamps
+	assert.NoError(t, os.Chtimes(hosts, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	assert.Eventually(t, func() bool {
+		return alias([]byte{192, 168, 0, 10}) == "printer"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "192.168.0.1", alias([]byte{192, 168, 0, 1}))
+
+	// aliases of removed file are dropped
+	assert.NoError(t, os.Remove(aliases))
+	assert.Eventually(t, func() bool {
+		return alias([]byte{10, 2, 0, 1}) == "10.2.0.1"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "printer", alias([]byte{192, 168, 0, 10}))
+	assert.False(t, e.filesChanged())
+}
+
+func TestEnrichHostAliasFallback(t *testing.T) {
+	e := &enrichHostAlias{}
+	e.Configure(map[string]interface{}{
+		"fallback": "empty",
+	})
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 10})
+	e.Enrich(f)
+	assert.Equal(t, "", *f.AsString("source_host_alias"))
+
+	assert.Panics(t, func() {
+		e.Configure(map[string]interface{}{
+			"fallback": "none",
+		})
+	})
+	assert.Panics(t, func() {
+		e.Configure(map[string]interface{}{
+			"alias_map": map[string]interface{}{
+				"not-an-ip": "x",
+			},
+		})
+	})
+}
@@ -0,0 +1,215 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+type localHost struct {
+	hostname string
+	mac      string
+}
+
+// localHosts maps local addresses to host names and MAC addresses, using DHCP leases and ARP table.
+type localHosts struct {
+	logger          *slog.Logger
+	dhcpdLeases     []string
+	dnsmasqLeases   []string
+	arpTable        string
+	refreshInterval time.Duration
+
+	mu     sync.RWMutex
+	hosts  map[string]*localHost
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func (l *localHosts) Configure(cfg map[string]interface{}) {
+	l.dhcpdLeases = cfgStringSlice(cfg, "dhcpd_leases")
+	l.dnsmasqLeases = cfgStringSlice(cfg, "dnsmasq_leases")
+	l.arpTable = cfgString(cfg, "arp_table", "/proc/net/arp")
+	l.refreshInterval = cfgPositiveDuration(cfg, "refresh_interval", time.Minute)
+}
+
+func (l *localHosts) Start() error {
+	l.logger = baseLogger.With("component", "local_hosts")
+	l.refresh()
+	l.stopCh = make(chan struct{})
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(l.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stopCh:
+				return
+			case <-ticker.C:
+				l.refresh()
+			}
+		}
+	}()
+	return nil
+}
+
+func (l *localHosts) Close() error {
+	if l.stopCh != nil {
+		close(l.stopCh)
+		l.wg.Wait()
+		l.stopCh = nil
+	}
+	return nil
+}
+
+func (l *localHosts) readFile(path string, fn func(io.Reader, map[string]*localHost), hosts map[string]*localHost) {
+	f, err := os.Open(path)
+	if err != nil {
+		l.logger.Warn("unable to read file", "path", path, "err", err)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	fn(f, hosts)
+}
+
+func (l *localHosts) refresh() {
+	hosts := map[string]*localHost{}
+	for _, path := range l.dhcpdLeases {
+		l.readFile(path, parseDhcpdLeases, hosts)
+	}
+	for _, path := range l.dnsmasqLeases {
+		l.readFile(path, parseDnsmasqLeases, hosts)
+	}
+	if len(l.arpTable) > 0 {
+		l.readFile(l.arpTable, parseArpTable, hosts)
+	}
+	l.mu.Lock()
+	l.hosts = hosts
+	l.mu.Unlock()
+	l.logger.Debug("refreshed local hosts", "count", len(hosts))
+}
+
+func hostEntry(hosts map[string]*localHost, ip string) *localHost {
+	h, ok := hosts[ip]
+	if !ok {
+		h = &localHost{}
+		hosts[ip] = h
+	}
+	return h
+}
+
+// parseDnsmasqLeases parses dnsmasq lease file, where each line is
+// "<expiry> <MAC address> <IP address> <hostname> <client ID>" and unknown hostname is "*"
+func parseDnsmasqLeases(r io.Reader, hosts map[string]*localHost) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || net.ParseIP(fields[2]) == nil {
+			continue
+		}
+		h := hostEntry(hosts, fields[2])
+		h.mac = strings.ToLower(fields[1])
+		if fields[3] != "*" {
+			h.hostname = fields[3]
+		}
+	}
+}
+
+// parseDhcpdLeases parses ISC dhcpd lease file. File is append-only, so later lease declarations
+// supersede earlier ones for the same address.
+func parseDhcpdLeases(r io.Reader, hosts map[string]*localHost) {
+	scanner := bufio.NewScanner(r)
+	var (
+		ip     string
+		active bool
+		lease  localHost
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(strings.TrimSuffix(line, ";"))
+		switch {
+		case len(fields) == 3 && fields[0] == "lease" && fields[2] == "{":
+			ip = fields[1]
+			active = true
+			lease = localHost{}
+		case len(ip) == 0:
+			continue
+		case line == "}":
+			if active {
+				h := hostEntry(hosts, ip)
+				*h = lease
+			} else {
+				delete(hosts, ip)
+			}
+			ip = ""
+		case len(fields) == 3 && fields[0] == "binding" && fields[1] == "state":
+			active = fields[2] == "active"
+		case len(fields) == 3 && fields[0] == "hardware" && fields[1] == "ethernet":
+			lease.mac = strings.ToLower(fields[2])
+		case len(fields) >= 2 && fields[0] == "client-hostname":
+			lease.hostname = strings.Trim(strings.Join(fields[1:], " "), `"`)
+		}
+	}
+}
+
+// parseArpTable parses Linux neighbour table as found in /proc/net/arp
+func parseArpTable(r io.Reader, hosts map[string]*localHost) {
+	scanner := bufio.NewScanner(r)
+	// skip header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// IP address, HW type, Flags, HW address, Mask, Device
+		if len(fields) < 4 || fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		hostEntry(hosts, fields[0]).mac = strings.ToLower(fields[3])
+	}
+}
+
+func (l *localHosts) enrichHost(flow *public.Flow, dir string) {
+	ip := flow.AsIp(dir + "_ip")
+	if ip == nil || !isLocalIp(ip) {
+		return
+	}
+	l.mu.RLock()
+	h, ok := l.hosts[ip.String()]
+	l.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if len(h.hostname) > 0 {
+		flow.AddAttr(dir+"_hostname", h.hostname)
+	}
+	if len(h.mac) > 0 {
+		flow.AddAttr(dir+"_mac", h.mac)
+	}
+}
+
+func (l *localHosts) Enrich(flow *public.Flow) {
+	l.enrichHost(flow, "source")
+	l.enrichHost(flow, "destination")
+}
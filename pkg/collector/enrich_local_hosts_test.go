@@ -0,0 +1,109 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalHosts(t *testing.T) {
+	dir := t.TempDir()
+	dhcpd := filepath.Join(dir, "dhcpd.leases")
+	dnsmasq := filepath.Join(dir, "dnsmasq.leases")
+	arp := filepath.Join(dir, "arp")
+	assert.NoError(t, os.WriteFile(dhcpd, []byte(`# The format of this file is documented in the dhcpd.leases(5) manual page.
+lease 192.168.0.10 {
+  starts 4 2026/10/15 10:00:00;
+  ends 4 2026/10/15 22:00:00;
+  binding state active;
+  hardware ethernet 00:11:22:33:44:55;
+  client-hostname "old-name";
+}
+lease 192.168.0.11 {
+  binding state active;
+  hardware ethernet 00:11:22:33:44:66;
+  client-hostname "printer";
+}
+lease 192.168.0.10 {
+  binding state active;
+  hardware ethernet 00:11:22:33:44:55;
+  client-hostname "laptop";
+}
+lease 192.168.0.11 {
+  binding state free;
+  hardware ethernet 00:11:22:33:44:66;
+}
+`), 0o600))
+	assert.NoError(t, os.WriteFile(dnsmasq, []byte(`1760600000 AA:BB:CC:DD:EE:01 192.168.0.20 phone 01:aa:bb:cc:dd:ee:01
+1760600000 aa:bb:cc:dd:ee:02 192.168.0.21 * *
+`), 0o600))
+	assert.NoError(t, os.WriteFile(arp, []byte(`IP address       HW type     Flags       HW address            Mask     Device
+192.168.0.21     0x1         0x2         aa:bb:cc:dd:ee:03     *        eth0
+192.168.0.30     0x1         0x2         aa:bb:cc:dd:ee:04     *        eth0
+192.168.0.31     0x1         0x0         00:00:00:00:00:00     *        eth0
+`), 0o600))
+
+	e := getEnricher("local_hosts")
+	e.Configure(map[string]interface{}{
+		"dhcpd_leases":   []interface{}{dhcpd},
+		"dnsmasq_leases": []interface{}{dnsmasq},
+		"arp_table":      arp,
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 10})
+	f.AddAttr("destination_ip", []byte{192, 168, 0, 20})
+	e.Enrich(f)
+	assert.Equal(t, "laptop", *f.AsString("source_hostname"))
+	assert.Equal(t, "00:11:22:33:44:55", *f.AsString("source_mac"))
+	assert.Equal(t, "phone", *f.AsString("destination_hostname"))
+	assert.Equal(t, "aa:bb:cc:dd:ee:01", *f.AsString("destination_mac"))
+
+	// released lease, MAC from neighbour table wins over lease
+	f = &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 11})
+	f.AddAttr("destination_ip", []byte{192, 168, 0, 21})
+	e.Enrich(f)
+	assert.Nil(t, f.AsString("source_hostname"))
+	assert.Nil(t, f.AsString("source_mac"))
+	assert.Nil(t, f.AsString("destination_hostname"))
+	assert.Equal(t, "aa:bb:cc:dd:ee:03", *f.AsString("destination_mac"))
+
+	// incomplete neighbour entry and remote address
+	f = &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 31})
+	f.AddAttr("destination_ip", []byte{1, 1, 1, 1})
+	e.Enrich(f)
+	assert.Nil(t, f.AsString("source_mac"))
+	assert.Nil(t, f.AsString("destination_mac"))
+
+	// refresh picks up changes
+	assert.NoError(t, os.WriteFile(dnsmasq, []byte(`1760600000 aa:bb:cc:dd:ee:05 192.168.0.20 tablet *
+`), 0o600))
+	e.(*localHosts).refresh()
+	f = &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 20})
+	e.Enrich(f)
+	assert.Equal(t, "tablet", *f.AsString("source_hostname"))
+}
@@ -0,0 +1,451 @@
+//	Copyright 2022 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+// dnsName is name of address, along with domain derived from it, so that domain is not derived for every flow
+type dnsName struct {
+	name   string
+	domain string
+}
+
+type reverseDNS struct {
+	ttl         time.Duration
+	cache       *ttlcache.Cache[string, dnsName]
+	cacheHits   prometheus.CounterFunc
+	cacheMisses prometheus.CounterFunc
+
+	tailPiHole         bool
+	piHoleLogFile      string
+	piHolePollInterval time.Duration
+	// IP to name, as observed in Pi-hole log or dnstap stream
+	observedNames *ttlcache.Cache[string, dnsName]
+	// DNS masq query session ID to original query
+	dnsMasqCache   *ttlcache.Cache[string, string]
+	piHoleLines    *prometheus.CounterVec
+	piHoleRestarts prometheus.Counter
+
+	dnstapListen   string
+	dnstapMessages *prometheus.CounterVec
+
+	// cancelled by Close, so that background work (including lookups) stops
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	servers       []string
+	resolver      *net.Resolver
+	lookupTimeout time.Duration
+	negativeTTL   time.Duration
+	// limits number of concurrent lookups
+	sem          chan struct{}
+	async        bool
+	pendingValue *string
+	pendingMu    sync.Mutex
+	pending      map[string]bool
+
+	// when set, domain is derived from name
+	domain *domainExtractor
+	// names of addresses that are not looked up
+	localName, remoteName dnsName
+
+	lookupLocal  bool
+	lookupRemote bool
+	ipAsUnknown  bool
+	logger       *slog.Logger
+}
+
+func (m *reverseDNS) Configure(cfg map[string]interface{}) {
+	tailPiholeObject, ok := cfg["tail_pihole"]
+	if ok {
+		m.tailPiHole, ok = tailPiholeObject.(bool)
+		if !ok {
+			panic("tail_pihole (if specified) must be a boolean, e.g. true (or false)")
+		}
+	}
+	m.piHoleLogFile = cfgString(cfg, "pihole_log_file", "/var/log/pihole/pihole.log")
+	m.piHolePollInterval = cfgPositiveDuration(cfg, "pihole_poll_interval", time.Second)
+	m.dnstapListen = cfgString(cfg, "dnstap_listen", "")
+	m.servers = cfgStringSlice(cfg, "servers")
+	m.lookupTimeout = cfgPositiveDuration(cfg, "lookup_timeout", 5*time.Second)
+	if concurrency := cfgInt(cfg, "max_concurrency", 16); concurrency > 0 {
+		m.sem = make(chan struct{}, concurrency)
+	} else {
+		panic("max_concurrency (if specified) must be a positive integer")
+	}
+	m.ttl = cfgPositiveDuration(cfg, "cache_duration", time.Hour)
+	m.negativeTTL = cfgPositiveDuration(cfg, "negative_cache_duration", m.ttl)
+	m.async = cfgBool(cfg, "async", false)
+	if _, ok := cfg["pending_value"]; ok {
+		pv := cfgString(cfg, "pending_value", "")
+		m.pendingValue = &pv
+	}
+	if d, ok := cfg["domain"]; ok {
+		dm, ok := d.(map[string]interface{})
+		if !ok {
+			panic("domain (if specified) must be a mapping")
+		}
+		m.domain = newDomainExtractor(dm)
+	}
+
+	lookupLocal, ok := cfg["lookup_local"]
+	if ok {
+		m.lookupLocal, ok = lookupLocal.(bool)
+		if !ok {
+			panic("lookup_local (if specified) must be a boolean, e.g. true (or false)")
+		}
+	}
+
+	lookupRemote, ok := cfg["lookup_remote"]
+	if ok {
+		m.lookupRemote, ok = lookupRemote.(bool)
+		if !ok {
+			panic("lookup_remote (if specified) must be a boolean, e.g. true (or false)")
+		}
+	}
+
+	ipAsUnknown, ok := cfg["ip_as_unknown"]
+	if ok {
+		m.ipAsUnknown, ok = ipAsUnknown.(bool)
+		if !ok {
+			panic("ip_as_unknown (if specified) must be a boolean, e.g. true (or false)")
+		}
+	}
+}
+
+// parsePiHoleLine processes single line of dnsmasq log (with log-queries=extra).
+// Both raw log file lines and output of "pihole -t" are accepted, it returns false if line can't be parsed.
+func (m *reverseDNS) parsePiHoleLine(line string) bool {
+	bits := strings.Fields(line)
+	// skip syslog timestamp that precedes process tag in raw log file
+	for i, bit := range bits {
+		if strings.HasPrefix(bit, "dnsmasq[") || strings.HasPrefix(bit, "pihole-FTL[") {
+			bits = bits[i:]
+			break
+		}
+	}
+	if len(bits) < 7 {
+		return false
+	}
+	sessionId := bits[1]
+	action := bits[3]
+	switch {
+	case strings.HasPrefix(action, "query"):
+		m.dnsMasqCache.Set(sessionId, bits[4], ttlcache.DefaultTTL)
+	case action == "cached", action == "reply":
+		resultIP := bits[6]
+		if bits[5] == "is" && resultIP != "<CNAME>" {
+			origQuery := m.dnsMasqCache.Get(sessionId)
+			if origQuery != nil {
+				m.observedNames.Set(resultIP, m.newName(origQuery.Value()), ttlcache.DefaultTTL)
+				m.logger.Debug("got entry", "tph-query", origQuery.Value(), "tph-result", resultIP)
+			}
+		}
+	}
+	return true
+}
+
+func (m *reverseDNS) processPiHoleLine(line string) {
+	if m.parsePiHoleLine(line) {
+		m.piHoleLines.WithLabelValues("parsed").Inc()
+	} else {
+		m.piHoleLines.WithLabelValues("failed").Inc()
+	}
+}
+
+func (m *reverseDNS) Close() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	// stop expiration goroutines of caches
+	for _, c := range []*ttlcache.Cache[string, dnsName]{m.cache, m.observedNames} {
+		if c != nil {
+			c.Stop()
+		}
+	}
+	if m.dnsMasqCache != nil {
+		m.dnsMasqCache.Stop()
+	}
+	return nil
+}
+
+func (m *reverseDNS) Describe(ch chan<- *prometheus.Desc) {
+	m.piHoleLines.Describe(ch)
+	m.piHoleRestarts.Describe(ch)
+	m.dnstapMessages.Describe(ch)
+	m.cacheHits.Describe(ch)
+	m.cacheMisses.Describe(ch)
+}
+
+func (m *reverseDNS) Collect(ch chan<- prometheus.Metric) {
+	m.piHoleLines.Collect(ch)
+	m.piHoleRestarts.Collect(ch)
+	m.dnstapMessages.Collect(ch)
+	m.cacheHits.Collect(ch)
+	m.cacheMisses.Collect(ch)
+}
+
+// newName derives domain of name, if enabled
+func (m *reverseDNS) newName(name string) dnsName {
+	n := dnsName{name: name}
+	if m.domain != nil {
+		n.domain = m.domain.extract(name)
+	}
+	return n
+}
+
+func (m *reverseDNS) unknownValue(key string) string {
+	if m.ipAsUnknown {
+		return key
+	}
+	return "unknown"
+}
+
+func (m *reverseDNS) newResolver() *net.Resolver {
+	if len(m.servers) == 0 {
+		return net.DefaultResolver
+	}
+	servers := make([]string, 0, len(m.servers))
+	for _, srv := range m.servers {
+		if _, _, err := net.SplitHostPort(srv); err != nil {
+			srv = net.JoinHostPort(srv, "53")
+		}
+		servers = append(servers, srv)
+	}
+	var next atomic.Uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			// servers are used in round-robin fashion
+			srv := servers[int(next.Add(1)-1)%len(servers)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv)
+		},
+	}
+}
+
+// resolve performs actual reverse lookup and stores result in cache.
+func (m *reverseDNS) resolve(key string) *ttlcache.Item[string, dnsName] {
+	m.logger.Debug("cache lookup", "key", key)
+	ctx, cancel := context.WithTimeout(m.ctx, m.lookupTimeout)
+	defer cancel()
+	names, err := m.resolver.LookupAddr(ctx, key)
+	if err != nil || len(names) == 0 {
+		m.logger.Debug("lookup failed", "key", key, "err", err)
+		return m.cache.Set(key, m.newName(m.unknownValue(key)), m.negativeTTL)
+	}
+	result := strings.TrimRight(names[0], ".")
+	m.logger.Debug("lookup result", "result", result)
+	return m.cache.Set(key, m.newName(result), ttlcache.DefaultTTL)
+}
+
+// resolveAsync schedules lookup in background, unless it is already in progress
+// or limit of concurrent lookups is reached (in which case it is retried next time address is seen).
+func (m *reverseDNS) resolveAsync(key string) {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+	if m.pending[key] {
+		return
+	}
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		return
+	}
+	m.pending[key] = true
+	go func() {
+		m.resolve(key)
+		<-m.sem
+		m.pendingMu.Lock()
+		delete(m.pending, key)
+		m.pendingMu.Unlock()
+	}()
+}
+
+// load resolves address missing in cache, number of concurrent lookups is limited
+func (m *reverseDNS) load(_ *ttlcache.Cache[string, dnsName], key string) *ttlcache.Item[string, dnsName] {
+	m.sem <- struct{}{}
+	defer func() {
+		<-m.sem
+	}()
+	return m.resolve(key)
+}
+
+// reverseLookup returns name of address and whether it was known without lookup,
+// that is observed in Pi-hole log or dnstap stream, or found in cache
+func (m *reverseDNS) reverseLookup(ip net.IP) (dnsName, bool) {
+	if isLocalIp(ip) {
+		if !m.lookupLocal {
+			return m.localName, false
+		}
+	} else {
+		if !m.lookupRemote {
+			return m.remoteName, false
+		}
+	}
+
+	s := ip.String()
+	if m.tailPiHole || len(m.dnstapListen) > 0 {
+		observed := m.observedNames.Get(s)
+		if observed != nil {
+			return observed.Value(), true
+		}
+	}
+	if m.async {
+		if item := m.cache.Get(s); item != nil {
+			return item.Value(), true
+		}
+		m.resolveAsync(s)
+		if m.pendingValue != nil {
+			return m.newName(*m.pendingValue), false
+		}
+		return m.newName(m.unknownValue(s)), false
+	}
+	cached := true
+	item := m.cache.Get(s, ttlcache.WithLoader[string, dnsName](ttlcache.LoaderFunc[string, dnsName](
+		func(c *ttlcache.Cache[string, dnsName], key string) *ttlcache.Item[string, dnsName] {
+			cached = false
+			return m.load(c, key)
+		},
+	)))
+	return item.Value(), cached
+}
+
+func (m *reverseDNS) Enrich(flow *public.Flow) {
+	_, _ = m.EnrichHit(flow)
+}
+
+// EnrichHit implements public.HitReporter, flow is hit when name of any of its addresses was known without lookup
+func (m *reverseDNS) EnrichHit(flow *public.Flow) (bool, error) {
+	hit := false
+	for _, dir := range []string{"source", "destination"} {
+		name, known := m.reverseLookup(flow.AsIp(dir + "_ip"))
+		hit = hit || known
+		flow.AddAttr(dir+"_dns", name.name)
+		if m.domain != nil {
+			flow.AddAttr(dir+"_domain", name.domain)
+		}
+	}
+	return hit, nil
+}
+
+func (m *reverseDNS) Start() error {
+	m.logger = baseLogger.With("component", "reverse_dns")
+	ctx, cancel := context.WithCancel(context.Background())
+	m.ctx, m.cancel = ctx, cancel
+	m.pending = map[string]bool{}
+	m.localName, m.remoteName = m.newName("local"), m.newName("remote")
+	m.resolver = m.newResolver()
+	opts := []ttlcache.Option[string, dnsName]{
+		ttlcache.WithTTL[string, dnsName](m.ttl),
+		ttlcache.WithDisableTouchOnHit[string, dnsName](),
+	}
+	// in synchronous mode, addresses missing in cache are resolved by reverseLookup using load
+	m.cache = ttlcache.New(opts...)
+	go m.cache.Start()
+
+	// cache to hold the results
+	m.observedNames = ttlcache.New(
+		ttlcache.WithTTL[string, dnsName](m.ttl), // IP to name
+	)
+	go m.observedNames.Start()
+	m.dnsMasqCache = ttlcache.New(
+		ttlcache.WithTTL[string, string](time.Minute),
+	)
+	go m.dnsMasqCache.Start()
+	m.cacheHits = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Subsystem: "reverse_dns",
+		Name:      "cache_hits",
+		Help:      "The total number of lookups answered from cache.",
+	}, func() float64 {
+		return float64(m.cache.Metrics().Hits)
+	})
+	m.cacheMisses = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Subsystem: "reverse_dns",
+		Name:      "cache_misses",
+		Help:      "The total number of lookups not found in cache.",
+	}, func() float64 {
+		return float64(m.cache.Metrics().Misses)
+	})
+	m.piHoleLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "reverse_dns",
+		Name:      "pihole_lines",
+		Help:      "The total number of Pi-hole log lines, by parsing result.",
+	}, []string{"result"})
+	m.piHoleRestarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: "reverse_dns",
+		Name:      "pihole_restarts",
+		Help:      "The total number of times following of Pi-hole log was restarted.",
+	})
+	m.dnstapMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "reverse_dns",
+		Name:      "dnstap_messages",
+		Help:      "The total number of received dnstap messages, by parsing result.",
+	}, []string{"result"})
+
+	if m.tailPiHole {
+		m.logger.Info("tailing pihole", "file", m.piHoleLogFile)
+		ff := &fileFollower{
+			path:         m.piHoleLogFile,
+			pollInterval: m.piHolePollInterval,
+			logger:       m.logger,
+		}
+		go ff.run(ctx, m.processPiHoleLine, func(error) {
+			m.piHoleRestarts.Inc()
+		})
+	}
+
+	if len(m.dnstapListen) > 0 {
+		network, address, err := parseListenAddress(m.dnstapListen)
+		if err != nil {
+			return err
+		}
+		d := &dnstapReceiver{
+			network: network,
+			address: address,
+			timeout: 10 * time.Second,
+			logger:  m.logger,
+			onAnswer: func(ip, name string) {
+				m.observedNames.Set(ip, m.newName(name), ttlcache.DefaultTTL)
+			},
+			onMessage: func(ok bool) {
+				if ok {
+					m.dnstapMessages.WithLabelValues("parsed").Inc()
+				} else {
+					m.dnstapMessages.WithLabelValues("failed").Inc()
+				}
+			},
+		}
+		if err = d.listen(); err != nil {
+			return err
+		}
+		m.logger.Info("receiving dnstap", "address", m.dnstapListen)
+		go d.serve(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dnstap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReverseLookupPiHoleLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pihole.log")
+	appendFile(t, path, "")
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{
+		"tail_pihole":          true,
+		"pihole_log_file":      path,
+		"pihole_poll_interval": "10ms",
+		"lookup_remote":        true,
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+	time.Sleep(50 * time.Millisecond)
+
+	appendFile(t, path, `Oct 19 06:53:33 dnsmasq[812]: 1342 192.168.0.10/51234 query[A] www.example.com from 192.168.0.10
+Oct 19 06:53:33 dnsmasq[812]: 1342 192.168.0.10/51234 forwarded www.example.com to 1.1.1.1
+Oct 19 06:53:33 dnsmasq[812]: 1342 192.168.0.10/51234 reply www.example.com is <CNAME>
+Oct 19 06:53:33 dnsmasq[812]: 1342 192.168.0.10/51234 reply edge.example.net is 93.184.215.14
+Oct  9 06:53:34 dnsmasq[812]: started
+`)
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{93, 184, 215, 14})
+	f.AddAttr("destination_ip", []byte{192, 168, 0, 10})
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(e.piHoleLines.WithLabelValues("failed")) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(4), testutil.ToFloat64(e.piHoleLines.WithLabelValues("parsed")))
+	e.Enrich(f)
+	assert.Equal(t, "www.example.com", *f.AsString("source_dns"))
+	assert.Equal(t, "local", *f.AsString("destination_dns"))
+}
+
+func dnstapResponse(t *testing.T, qname string, answers ...dns.RR) []byte {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(qname), dns.TypeA)
+	m.Response = true
+	m.Answer = answers
+	packed, err := m.Pack()
+	assert.NoError(t, err)
+	data, err := proto.Marshal(&dnstap.Dnstap{
+		Type: dnstap.Dnstap_MESSAGE.Enum(),
+		Message: &dnstap.Message{
+			Type:            dnstap.Message_CLIENT_RESPONSE.Enum(),
+			ResponseMessage: packed,
+		},
+	})
+	assert.NoError(t, err)
+	return data
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	assert.NoError(t, err)
+	return rr
+}
+
+func TestReverseLookupDnstap(t *testing.T) {
+	port := getFreePort("tcp", t)
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{
+		"dnstap_listen": fmt.Sprintf("tcp:127.0.0.1:%d", port),
+		"lookup_remote": true,
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	out, err := dnstap.NewFrameStreamSockOutput(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	assert.NoError(t, err)
+	go out.RunOutputLoop()
+	out.GetOutputChannel() <- dnstapResponse(t, "www.example.com",
+		mustRR(t, "www.example.com. 60 IN CNAME edge.example.net."),
+		mustRR(t, "edge.example.net. 60 IN A 93.184.215.14"))
+	out.GetOutputChannel() <- []byte("garbage")
+	out.Close()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(e.dnstapMessages.WithLabelValues("failed")) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.dnstapMessages.WithLabelValues("parsed")))
+
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{192, 168, 0, 10})
+	f.AddAttr("destination_ip", []byte{93, 184, 215, 14})
+	e.Enrich(f)
+	assert.Equal(t, "www.example.com", *f.AsString("destination_dns"))
+}
+
+func TestParseListenAddress(t *testing.T) {
+	network, address, err := parseListenAddress("unix:/run/dnstap.sock")
+	assert.NoError(t, err)
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/run/dnstap.sock", address)
+	network, address, err = parseListenAddress("tcp:127.0.0.1:6000")
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, "127.0.0.1:6000", address)
+	_, _, err = parseListenAddress("udp:127.0.0.1:6000")
+	assert.Error(t, err)
+	_, _, err = parseListenAddress("/run/dnstap.sock")
+	assert.Error(t, err)
+}
+
+func TestDnstapListenUnix(t *testing.T) {
+	// socket path length is limited, so temporary directory of test may be too long
+	dir, err := os.MkdirTemp("", "dnstap")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "dnstap.sock")
+
+	// regular file is left untouched
+	assert.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+	d := &dnstapReceiver{network: "unix", address: path}
+	assert.ErrorContains(t, d.listen(), "is not a socket")
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.NoError(t, os.Remove(path))
+
+	// stale socket from previous run is replaced
+	l, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.NoError(t, l.Close())
+	assert.NoError(t, d.listen())
+	assert.NoError(t, d.listener.Close())
+}
+
+// startDNSServer starts DNS server on loopback, which answers PTR queries from provided map.
+// Queries for names not in map are answered with NXDOMAIN after given delay.
+func startDNSServer(t *testing.T, ptrs map[string]string, delay time.Duration) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := &dns.Msg{}
+			m.SetReply(r)
+			if name, ok := ptrs[r.Question[0].Name]; ok {
+				m.Answer = append(m.Answer, &dns.PTR{
+					Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: 60},
+					Ptr: name,
+				})
+			} else {
+				time.Sleep(delay)
+				m.Rcode = dns.RcodeNameError
+			}
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() {
+		_ = srv.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
+func TestReverseLookupCustomResolver(t *testing.T) {
+	addr := startDNSServer(t, map[string]string{
+		"8.8.8.8.in-addr.arpa.": "dns.google.",
+	}, 500*time.Millisecond)
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{
+		"servers":        []interface{}{addr},
+		"lookup_timeout": "100ms",
+		"lookup_remote":  true,
+		"ip_as_unknown":  true,
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{8, 8, 8, 8})
+	f.AddAttr("destination_ip", []byte{1, 2, 3, 4})
+	start := time.Now()
+	hit, err := e.EnrichHit(f)
+	assert.NoError(t, err)
+	assert.False(t, hit)
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+	assert.Equal(t, "dns.google", *f.AsString("source_dns"))
+	assert.Equal(t, "1.2.3.4", *f.AsString("destination_dns"))
+
+	// both names, including negative result, are answered from cache now
+	hit, err = e.EnrichHit(f)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, float64(2), testutil.ToFloat64(e.cacheHits))
+	assert.Equal(t, float64(2), testutil.ToFloat64(e.cacheMisses))
+}
+
+func TestReverseLookupAsync(t *testing.T) {
+	addr := startDNSServer(t, map[string]string{
+		"8.8.8.8.in-addr.arpa.": "dns.google.",
+	}, 0)
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{
+		"servers":                 []interface{}{addr},
+		"async":                   true,
+		"pending_value":           "pending",
+		"negative_cache_duration": "1m",
+		"lookup_remote":           true,
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{8, 8, 8, 8})
+	f.AddAttr("destination_ip", []byte{1, 2, 3, 4})
+	e.Enrich(f)
+	assert.Equal(t, "pending", *f.AsString("source_dns"))
+	assert.Equal(t, "pending", *f.AsString("destination_dns"))
+	assert.Eventually(t, func() bool {
+		e.Enrich(f)
+		return *f.AsString("source_dns") == "dns.google" && *f.AsString("destination_dns") == "unknown"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestReverseLookupAsyncClose(t *testing.T) {
+	// server never answers in time
+	addr := startDNSServer(t, map[string]string{}, 5*time.Second)
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{
+		"servers":        []interface{}{addr},
+		"async":          true,
+		"lookup_timeout": "10s",
+		"lookup_remote":  true,
+	})
+	assert.NoError(t, e.Start())
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{8, 8, 8, 8})
+	f.AddAttr("destination_ip", []byte{1, 2, 3, 4})
+	e.Enrich(f)
+	assert.NoError(t, e.Close())
+	// lookups in progress are cancelled
+	assert.Eventually(t, func() bool {
+		e.pendingMu.Lock()
+		defer e.pendingMu.Unlock()
+		return len(e.pending) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReverseLookupConfigure(t *testing.T) {
+	e := &reverseDNS{}
+	e.Configure(map[string]interface{}{})
+	assert.Equal(t, time.Hour, e.ttl)
+	assert.Equal(t, time.Hour, e.negativeTTL)
+	assert.Equal(t, 5*time.Second, e.lookupTimeout)
+	assert.Equal(t, 16, cap(e.sem))
+	for _, key := range []string{"cache_duration", "negative_cache_duration", "lookup_timeout", "pihole_poll_interval"} {
+		assert.Panics(t, func() {
+			(&reverseDNS{}).Configure(map[string]interface{}{key: "0s"})
+		}, key)
+	}
+}
@@ -0,0 +1,220 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// scriptEnricher runs user-supplied Starlark function for every flow.
+// Script is compiled once, then its globals are frozen, so that function can be called concurrently.
+type scriptEnricher struct {
+	source   string
+	filename string
+	timeout  time.Duration
+	maxSteps uint64
+
+	fn starlark.Callable
+}
+
+var scriptBuiltins = starlark.StringDict{
+	"in_network": starlark.NewBuiltin("in_network", scriptInNetwork),
+}
+
+// scriptInNetwork implements in_network(ip, cidr, ...), which returns True if address belongs to any of networks
+func scriptInNetwork(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) > 0 || len(args) < 2 {
+		return nil, fmt.Errorf("%s: expected address and at least one network", b.Name())
+	}
+	s, ok := starlark.AsString(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%s: address must be a string, got %s", b.Name(), args[0].Type())
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return starlark.False, nil
+	}
+	for _, arg := range args[1:] {
+		cidr, ok := starlark.AsString(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s: network must be a string, got %s", b.Name(), arg.Type())
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+		if n.Contains(ip) {
+			return starlark.True, nil
+		}
+	}
+	return starlark.False, nil
+}
+
+func (s *scriptEnricher) Configure(cfg map[string]interface{}) (err error) {
+	defer cfgRecover(&err)
+	s.source = cfgString(cfg, "script", "")
+	s.filename = "script.star"
+	if file := cfgString(cfg, "file", ""); len(file) > 0 {
+		if len(s.source) > 0 {
+			return errors.New("only one of script and file can be specified")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		s.source = string(data)
+		s.filename = file
+	}
+	s.timeout = cfgPositiveDuration(cfg, "timeout", 10*time.Millisecond)
+	maxSteps := cfgInt(cfg, "max_steps", 100000)
+	if maxSteps < 1 {
+		return errors.New("max_steps (if specified) must be a positive integer")
+	}
+	s.maxSteps = uint64(maxSteps)
+	return nil
+}
+
+func (s *scriptEnricher) Start(context.Context) error {
+	if len(s.source) == 0 {
+		return fmt.Errorf("script or file must be specified")
+	}
+	thread := &starlark.Thread{Name: "init"}
+	thread.SetMaxExecutionSteps(s.maxSteps)
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{
+		While:           true,
+		TopLevelControl: true,
+		Set:             true,
+	}, thread, s.filename, s.source, scriptBuiltins)
+	if err != nil {
+		return err
+	}
+	fn, ok := globals["enrich"].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("%s: script must define function enrich(flow)", s.filename)
+	}
+	globals.Freeze()
+	s.fn = fn
+	return nil
+}
+
+func (s *scriptEnricher) Close() error { return nil }
+
+// toStarlark converts attribute value to Starlark value, addresses are represented as strings
+func toStarlark(v interface{}) (starlark.Value, bool) {
+	switch x := v.(type) {
+	case []byte:
+		if len(x) == net.IPv4len || len(x) == net.IPv6len {
+			return starlark.String(net.IP(x).String()), true
+		}
+	case string:
+		return starlark.String(x), true
+	case bool:
+		return starlark.Bool(x), true
+	case uint32:
+		return starlark.MakeUint64(uint64(x)), true
+	case uint64:
+		return starlark.MakeUint64(x), true
+	case float64:
+		return starlark.Float(x), true
+	}
+	return nil, false
+}
+
+// fromStarlark converts Starlark value back to attribute value. Integers take type of existing attribute,
+// new ones are stored as uint32 when they fit.
+func fromStarlark(v starlark.Value, old interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case starlark.String:
+		return string(x), true
+	case starlark.Bool:
+		return bool(x), true
+	case starlark.Float:
+		return float64(x), true
+	case starlark.Int:
+		u, ok := x.Uint64()
+		if !ok {
+			return nil, false
+		}
+		if _, is64 := old.(uint64); is64 {
+			return u, true
+		}
+		if u > math.MaxUint32 {
+			return u, old == nil
+		}
+		return uint32(u), true
+	}
+	return nil, false
+}
+
+func (s *scriptEnricher) Enrich(flow *public.Flow) error {
+	attrs := flow.Attrs()
+	d := starlark.NewDict(len(attrs))
+	for k, v := range attrs {
+		if sv, ok := toStarlark(v); ok {
+			_ = d.SetKey(starlark.String(k), sv)
+		}
+	}
+	thread := &starlark.Thread{Name: "enrich"}
+	thread.SetMaxExecutionSteps(s.maxSteps)
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(s.timeout, func() {
+		timedOut.Store(true)
+		thread.Cancel("timeout")
+	})
+	_, err := starlark.Call(thread, s.fn, starlark.Tuple{d}, nil)
+	timer.Stop()
+	if err != nil {
+		switch {
+		case timedOut.Load():
+			return fmt.Errorf("script exceeded time limit of %v: %w", s.timeout, err)
+		case thread.ExecutionSteps() >= s.maxSteps:
+			return fmt.Errorf("script exceeded limit of %d steps: %w", s.maxSteps, err)
+		}
+		return err
+	}
+	var rejected []string
+	for _, item := range d.Items() {
+		k, ok := starlark.AsString(item[0])
+		if !ok {
+			continue
+		}
+		old := attrs[k]
+		if prev, ok := toStarlark(old); ok {
+			if eq, err := starlark.Equal(prev, item[1]); err == nil && eq {
+				continue
+			}
+		}
+		val, ok := fromStarlark(item[1], old)
+		// existing attributes can only be replaced by value of same type
+		if !ok || (old != nil && reflect.TypeOf(old) != reflect.TypeOf(val)) {
+			rejected = append(rejected, k)
+			continue
+		}
+		flow.AddAttr(k, val)
+	}
+	return rejectedAttrsError(rejected)
+}
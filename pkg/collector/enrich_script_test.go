@@ -0,0 +1,137 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func scriptFlow() *public.Flow {
+	f := &public.Flow{}
+	f.AddAttr("source_ip", []byte{10, 1, 2, 3})
+	f.AddAttr("destination_ip", []byte{192, 168, 0, 10})
+	f.AddAttr("sampler", []byte{127, 0, 0, 1})
+	f.AddAttr("destination_port", uint32(8443))
+	f.AddAttr("input_interface", uint32(3))
+	f.AddAttr("bytes", uint64(1500))
+	return f
+}
+
+func TestScriptEnricher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "enrich.star")
+	assert.NoError(t, os.WriteFile(file, []byte(`
+PORT_BUCKETS = [(1024, "system"), (49152, "registered")]
+
+def port_bucket(port):
+    for limit, name in PORT_BUCKETS:
+        if port < limit:
+            return name
+    return "dynamic"
+
+def enrich(flow):
+    flow["port_bucket"] = port_bucket(flow["destination_port"])
+    flow["input"] = "%s/%d" % (flow["sampler"], flow["input_interface"])
+    flow["lab"] = in_network(flow["source_ip"], "10.1.0.0/16", "10.2.0.0/16")
+    flow["kbytes"] = flow["bytes"] // 1024
+    flow["bytes"] = "oops"
+`), 0o600))
+	e, _ := newEnricher("script")
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"file": file,
+	}))
+	assert.NoError(t, e.Start(context.Background()))
+	defer func(e public.EnricherV2) {
+		_ = e.Close()
+	}(e)
+
+	// compiled script is shared by concurrent callers
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f := scriptFlow()
+			// other attributes are added even though one was rejected
+			assert.EqualError(t, e.Enrich(f), "attributes rejected because of their type: bytes")
+			assert.Equal(t, "registered", *f.AsString("port_bucket"))
+			assert.Equal(t, "127.0.0.1/3", *f.AsString("input"))
+			assert.Equal(t, true, f.Raw("lab"))
+			assert.Equal(t, uint32(1), *f.AsUint32("kbytes"))
+			// type of existing attribute is kept
+			assert.Equal(t, uint64(1500), f.Raw("bytes"))
+			// addresses are left intact
+			assert.Equal(t, []byte{10, 1, 2, 3}, f.Raw("source_ip"))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestScriptEnricherLimits(t *testing.T) {
+	e := &scriptEnricher{}
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"script": `
+def enrich(flow):
+    while True:
+        pass
+`,
+		"max_steps": 1000,
+	}))
+	assert.NoError(t, e.Start(context.Background()))
+	f := scriptFlow()
+	assert.ErrorContains(t, e.Enrich(f), "limit of 1000 steps")
+
+	e = &scriptEnricher{}
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"script": `
+def enrich(flow):
+    while True:
+        pass
+`,
+		"max_steps": 1 << 40,
+		"timeout":   "20ms",
+	}))
+	assert.NoError(t, e.Start(context.Background()))
+	assert.ErrorContains(t, e.Enrich(f), "time limit of 20ms")
+
+	e = &scriptEnricher{}
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"script": `
+def enrich(flow):
+    flow["x"] = flow["missing"]
+`,
+	}))
+	assert.NoError(t, e.Start(context.Background()))
+	f = scriptFlow()
+	assert.ErrorContains(t, e.Enrich(f), "missing")
+	assert.Nil(t, f.Raw("x"))
+
+	// script without enrich function, syntax error and I/O attempts are rejected at start
+	for _, src := range []string{"x = 1", "def enrich(flow)", `load("os.star", "open")`} {
+		e = &scriptEnricher{}
+		assert.NoError(t, e.Configure(map[string]interface{}{"script": src}))
+		assert.Error(t, e.Start(context.Background()), src)
+	}
+	assert.EqualError(t, (&scriptEnricher{}).Configure(map[string]interface{}{"script": "x", "file": "y"}),
+		"only one of script and file can be specified")
+	assert.EqualError(t, (&scriptEnricher{}).Configure(map[string]interface{}{"script": "x", "timeout": 5}),
+		"timeout (if specified) must be a Go duration string, e.g. 1h")
+}
@@ -0,0 +1,173 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+var (
+	// services file of Debian netbase package, which is curated subset of IANA registry
+	//go:embed data/services
+	embeddedServices string
+
+	serviceProtocols = map[string]uint32{
+		"tcp":  0x06,
+		"udp":  0x11,
+		"dccp": 0x21,
+		"sctp": 0x84,
+	}
+)
+
+type serviceKey struct {
+	proto uint32
+	port  uint32
+}
+
+type serviceName struct {
+	skipEmbedded bool
+	servicesFile string
+	overrides    map[string]string
+	services     map[serviceKey]string
+}
+
+// parseServicePort parses "port/proto" notation, as used in /etc/services
+func parseServicePort(s string) (serviceKey, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return serviceKey{}, fmt.Errorf("invalid port/protocol: %s", s)
+	}
+	port, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return serviceKey{}, fmt.Errorf("invalid port/protocol: %s", s)
+	}
+	proto, ok := serviceProtocols[strings.ToLower(parts[1])]
+	if !ok {
+		return serviceKey{}, fmt.Errorf("unsupported protocol: %s", s)
+	}
+	return serviceKey{proto: proto, port: uint32(port)}, nil
+}
+
+// loadServices reads services in /etc/services format, entries that are already present are kept.
+func loadServices(r io.Reader, dst map[serviceKey]string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		key, err := parseServicePort(fields[1])
+		if err != nil {
+			continue
+		}
+		if _, ok := dst[key]; !ok {
+			dst[key] = fields[0]
+		}
+	}
+}
+
+func isPortProtocol(proto uint32) bool {
+	for _, p := range serviceProtocols {
+		if p == proto {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *serviceName) Configure(cfg map[string]interface{}) {
+	s.skipEmbedded = !cfgBool(cfg, "use_embedded", true)
+	s.servicesFile = cfgString(cfg, "services_file", "")
+	s.overrides = map[string]string{}
+	if o, ok := cfg["overrides"]; ok {
+		m, ok := o.(map[string]interface{})
+		if !ok {
+			panic("overrides (if specified) must be a mapping of port/protocol to service name")
+		}
+		s.overrides = toStringMap(m)
+	}
+}
+
+func (s *serviceName) Start() error {
+	s.services = map[serviceKey]string{}
+	for k, v := range s.overrides {
+		key, err := parseServicePort(k)
+		if err != nil {
+			return err
+		}
+		s.services[key] = v
+	}
+	if len(s.servicesFile) > 0 {
+		f, err := os.Open(s.servicesFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		loadServices(f, s.services)
+	}
+	if !s.skipEmbedded {
+		loadServices(strings.NewReader(embeddedServices), s.services)
+	}
+	return nil
+}
+
+func (s *serviceName) Close() error {
+	return nil
+}
+
+func (s *serviceName) Enrich(flow *public.Flow) {
+	proto := flow.AsUint32("proto")
+	srcPort := flow.AsUint32("source_port")
+	dstPort := flow.AsUint32("destination_port")
+	if proto == nil || srcPort == nil || dstPort == nil || !isPortProtocol(*proto) {
+		return
+	}
+	srcSvc, srcOk := s.services[serviceKey{proto: *proto, port: *srcPort}]
+	dstSvc, dstOk := s.services[serviceKey{proto: *proto, port: *dstPort}]
+	switch {
+	// when both sides are known, lower port is more likely to be the server side
+	case srcOk && dstOk:
+		if *srcPort < *dstPort {
+			flow.AddAttr("service", srcSvc)
+			flow.AddAttr("application_port", *srcPort)
+		} else {
+			flow.AddAttr("service", dstSvc)
+			flow.AddAttr("application_port", *dstPort)
+		}
+	case dstOk:
+		flow.AddAttr("service", dstSvc)
+		flow.AddAttr("application_port", *dstPort)
+	case srcOk:
+		flow.AddAttr("service", srcSvc)
+		flow.AddAttr("application_port", *srcPort)
+	default:
+		// neither port identifies application, typically both are ephemeral
+		flow.AddAttr("service", "unknown")
+	}
+}
@@ -0,0 +1,104 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func serviceFlow(proto, srcPort, dstPort uint32) *public.Flow {
+	f := &public.Flow{}
+	f.AddAttr("proto", proto)
+	f.AddAttr("source_port", srcPort)
+	f.AddAttr("destination_port", dstPort)
+	return f
+}
+
+func TestServiceName(t *testing.T) {
+	svcFile := filepath.Join(t.TempDir(), "services")
+	assert.NoError(t, os.WriteFile(svcFile, []byte(`
+# custom services
+grafana		3000/tcp
+https		443/tcp		# must not override embedded entry
+`), 0o600))
+	e := getEnricher("service_name")
+	e.Configure(map[string]interface{}{
+		"services_file": svcFile,
+		"overrides": map[string]interface{}{
+			"8080/tcp": "proxy",
+		},
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	f := serviceFlow(6, 51234, 443)
+	e.Enrich(f)
+	assert.Equal(t, "https", *f.AsString("service"))
+	assert.Equal(t, uint32(443), *f.AsUint32("application_port"))
+
+	// reply direction
+	f = serviceFlow(17, 53, 40000)
+	e.Enrich(f)
+	assert.Equal(t, "domain", *f.AsString("service"))
+	assert.Equal(t, uint32(53), *f.AsUint32("application_port"))
+
+	f = serviceFlow(6, 3000, 41000)
+	e.Enrich(f)
+	assert.Equal(t, "grafana", *f.AsString("service"))
+
+	f = serviceFlow(6, 8080, 40000)
+	e.Enrich(f)
+	assert.Equal(t, "proxy", *f.AsString("service"))
+
+	// both sides are well-known
+	f = serviceFlow(17, 123, 53)
+	e.Enrich(f)
+	assert.Equal(t, "domain", *f.AsString("service"))
+
+	f = serviceFlow(6, 41000, 40000)
+	e.Enrich(f)
+	assert.Equal(t, "unknown", *f.AsString("service"))
+	assert.Nil(t, f.Raw("application_port"))
+
+	// ICMP has no ports
+	f = serviceFlow(1, 0, 0)
+	e.Enrich(f)
+	assert.Nil(t, f.Raw("service"))
+}
+
+func TestServiceNameWithoutEmbedded(t *testing.T) {
+	e := &serviceName{}
+	e.Configure(map[string]interface{}{
+		"use_embedded": false,
+	})
+	assert.NoError(t, e.Start())
+	f := serviceFlow(6, 51234, 443)
+	e.Enrich(f)
+	assert.Equal(t, "unknown", *f.AsString("service"))
+
+	e.Configure(map[string]interface{}{
+		"overrides": map[string]interface{}{
+			"443/icmp": "invalid",
+		},
+	})
+	assert.Error(t, e.Start())
+}
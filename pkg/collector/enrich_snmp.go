@@ -0,0 +1,352 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+const (
+	oidIfName      = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHighSpeed = ".1.3.6.1.2.1.31.1.1.1.15"
+	oidIfAlias     = ".1.3.6.1.2.1.31.1.1.1.18"
+)
+
+var (
+	snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"":       gosnmp.NoAuth,
+		"md5":    gosnmp.MD5,
+		"sha":    gosnmp.SHA,
+		"sha224": gosnmp.SHA224,
+		"sha256": gosnmp.SHA256,
+		"sha384": gosnmp.SHA384,
+		"sha512": gosnmp.SHA512,
+	}
+	snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"":        gosnmp.NoPriv,
+		"des":     gosnmp.DES,
+		"aes":     gosnmp.AES,
+		"aes192":  gosnmp.AES192,
+		"aes256":  gosnmp.AES256,
+		"aes192c": gosnmp.AES192C,
+		"aes256c": gosnmp.AES256C,
+	}
+	snmpSecurityLevels = map[string]gosnmp.SnmpV3MsgFlags{
+		"no_auth_no_priv": gosnmp.NoAuthNoPriv,
+		"auth_no_priv":    gosnmp.AuthNoPriv,
+		"auth_priv":       gosnmp.AuthPriv,
+	}
+)
+
+type snmpIfEntry struct {
+	name  string
+	alias string
+	// speed in bits per second
+	speed uint64
+}
+
+type snmpInterface struct {
+	logger   *slog.Logger
+	interval time.Duration
+	timeout  time.Duration
+	retries  int
+	port     uint16
+	version  gosnmp.SnmpVersion
+	// SNMP v2c
+	community string
+	// SNMP v3
+	username      string
+	securityLevel gosnmp.SnmpV3MsgFlags
+	authProtocol  gosnmp.SnmpV3AuthProtocol
+	authPass      string
+	privProtocol  gosnmp.SnmpV3PrivProtocol
+	privPass      string
+
+	samplers []string
+	// whether samplers seen in flows are polled too, up to maxDiscovered of them
+	discover      bool
+	maxDiscovered int
+
+	mu sync.RWMutex
+	// sampler address => ifIndex => interface details
+	ifaces map[string]map[uint32]*snmpIfEntry
+	// samplers that are polled periodically
+	known map[string]bool
+	// number of samplers in known that were discovered from flows
+	discovered int
+	pending    chan string
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+}
+
+func (s *snmpInterface) Configure(cfg map[string]interface{}) {
+	s.interval = cfgPositiveDuration(cfg, "interval", 5*time.Minute)
+	s.timeout = cfgPositiveDuration(cfg, "timeout", 5*time.Second)
+	s.retries = cfgInt(cfg, "retries", 1)
+	port := cfgInt(cfg, "port", 161)
+	if port <= 0 || port > 65535 {
+		panic("port (if specified) must be valid UDP port number")
+	}
+	s.port = uint16(port)
+	switch v := cfgString(cfg, "version", "2c"); v {
+	case "2c":
+		s.version = gosnmp.Version2c
+	case "3":
+		s.version = gosnmp.Version3
+	default:
+		panic(fmt.Sprintf("unsupported SNMP version: %s", v))
+	}
+	s.community = cfgString(cfg, "community", "public")
+	s.username = cfgString(cfg, "username", "")
+
+	var ok bool
+	level := cfgString(cfg, "security_level", "no_auth_no_priv")
+	if s.securityLevel, ok = snmpSecurityLevels[level]; !ok {
+		panic(fmt.Sprintf("unsupported SNMP security level: %s", level))
+	}
+	proto := strings.ToLower(cfgString(cfg, "auth_protocol", ""))
+	if s.authProtocol, ok = snmpAuthProtocols[proto]; !ok {
+		panic(fmt.Sprintf("unsupported SNMP auth protocol: %s", proto))
+	}
+	s.authPass = cfgString(cfg, "auth_passphrase", "")
+	proto = strings.ToLower(cfgString(cfg, "priv_protocol", ""))
+	if s.privProtocol, ok = snmpPrivProtocols[proto]; !ok {
+		panic(fmt.Sprintf("unsupported SNMP privacy protocol: %s", proto))
+	}
+	s.privPass = cfgString(cfg, "priv_passphrase", "")
+	s.samplers = cfgStringSlice(cfg, "samplers")
+	s.discover = cfgBool(cfg, "discover", false)
+	s.maxDiscovered = cfgInt(cfg, "max_discovered", 64)
+	if s.maxDiscovered < 1 {
+		panic("max_discovered (if specified) must be a positive integer")
+	}
+}
+
+func (s *snmpInterface) Start() error {
+	s.logger = baseLogger.With("component", "snmp_interface")
+	s.ifaces = map[string]map[uint32]*snmpIfEntry{}
+	s.known = map[string]bool{}
+	s.pending = make(chan string, 16)
+	s.stopCh = make(chan struct{})
+	for _, sampler := range s.samplers {
+		s.known[sampler] = true
+	}
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+func (s *snmpInterface) Close() error {
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.wg.Wait()
+		s.stopCh = nil
+	}
+	return nil
+}
+
+func (s *snmpInterface) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	s.pollAll()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case sampler := <-s.pending:
+			s.poll(sampler)
+		case <-ticker.C:
+			s.pollAll()
+		}
+	}
+}
+
+func (s *snmpInterface) pollAll() {
+	s.mu.RLock()
+	samplers := make([]string, 0, len(s.known))
+	for sampler := range s.known {
+		samplers = append(samplers, sampler)
+	}
+	s.mu.RUnlock()
+	for _, sampler := range samplers {
+		s.poll(sampler)
+	}
+}
+
+func (s *snmpInterface) newClient(target string) *gosnmp.GoSNMP {
+	client := &gosnmp.GoSNMP{
+		Target:             target,
+		Port:               s.port,
+		Transport:          "udp",
+		Version:            s.version,
+		Community:          s.community,
+		Timeout:            s.timeout,
+		Retries:            s.retries,
+		ExponentialTimeout: true,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     50,
+	}
+	if s.version == gosnmp.Version3 {
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = s.securityLevel
+		client.SecurityParameters = &gosnmp.UsmSecurityParameters{
+			UserName:                 s.username,
+			AuthenticationProtocol:   s.authProtocol,
+			AuthenticationPassphrase: s.authPass,
+			PrivacyProtocol:          s.privProtocol,
+			PrivacyPassphrase:        s.privPass,
+		}
+	}
+	return client
+}
+
+// walkColumn walks single IF-MIB column and calls fn for every row with ifIndex
+func walkColumn(client *gosnmp.GoSNMP, oid string, fn func(ifIndex uint32, pdu gosnmp.SnmpPDU)) error {
+	pdus, err := client.BulkWalkAll(oid)
+	if err != nil {
+		return err
+	}
+	for _, pdu := range pdus {
+		idx, err := strconv.ParseUint(pdu.Name[strings.LastIndex(pdu.Name, ".")+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		fn(uint32(idx), pdu)
+	}
+	return nil
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	if b, ok := pdu.Value.([]byte); ok {
+		return string(b)
+	}
+	return ""
+}
+
+func (s *snmpInterface) walkIfTable(target string) (map[uint32]*snmpIfEntry, error) {
+	client := s.newClient(target)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Conn.Close()
+	}()
+	result := map[uint32]*snmpIfEntry{}
+	entry := func(ifIndex uint32) *snmpIfEntry {
+		if e, ok := result[ifIndex]; ok {
+			return e
+		}
+		e := &snmpIfEntry{}
+		result[ifIndex] = e
+		return e
+	}
+	if err := walkColumn(client, oidIfName, func(ifIndex uint32, pdu gosnmp.SnmpPDU) {
+		entry(ifIndex).name = pduString(pdu)
+	}); err != nil {
+		return nil, err
+	}
+	if err := walkColumn(client, oidIfAlias, func(ifIndex uint32, pdu gosnmp.SnmpPDU) {
+		entry(ifIndex).alias = pduString(pdu)
+	}); err != nil {
+		return nil, err
+	}
+	if err := walkColumn(client, oidIfHighSpeed, func(ifIndex uint32, pdu gosnmp.SnmpPDU) {
+		// ifHighSpeed is in units of 1,000,000 bits per second
+		entry(ifIndex).speed = gosnmp.ToBigInt(pdu.Value).Uint64() * 1_000_000
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *snmpInterface) poll(sampler string) {
+	s.logger.Debug("polling interfaces", "sampler", sampler)
+	ifaces, err := s.walkIfTable(sampler)
+	if err != nil {
+		s.logger.Warn("unable to poll interfaces", "sampler", sampler, "err", err)
+		return
+	}
+	s.mu.Lock()
+	s.ifaces[sampler] = ifaces
+	s.mu.Unlock()
+	s.logger.Debug("polled interfaces", "sampler", sampler, "count", len(ifaces))
+}
+
+// lookup gets interface details for given sampler. When discovery is enabled, unknown samplers are scheduled
+// for polling, until limit of discovered samplers is reached.
+func (s *snmpInterface) lookup(sampler string, ifIndex uint32) *snmpIfEntry {
+	s.mu.RLock()
+	known := s.known[sampler]
+	full := s.discovered >= s.maxDiscovered
+	ifaces := s.ifaces[sampler]
+	s.mu.RUnlock()
+	if !known && s.discover && !full {
+		s.mu.Lock()
+		if !s.known[sampler] && s.discovered < s.maxDiscovered {
+			s.known[sampler] = true
+			s.discovered++
+			if s.discovered == s.maxDiscovered {
+				s.logger.Warn("limit of discovered samplers reached, other samplers will not be polled", "limit", s.maxDiscovered)
+			}
+			select {
+			case s.pending <- sampler:
+			default:
+				// will be picked up by next periodic poll
+			}
+		}
+		s.mu.Unlock()
+	}
+	if ifaces == nil {
+		return nil
+	}
+	return ifaces[ifIndex]
+}
+
+func (s *snmpInterface) enrichInterface(flow *public.Flow, sampler, attr string) {
+	ifIndex := flow.AsUint32(attr)
+	if ifIndex == nil {
+		return
+	}
+	if e := s.lookup(sampler, *ifIndex); e != nil {
+		if len(e.name) > 0 {
+			flow.AddAttr(attr+"_name", e.name)
+		}
+		if len(e.alias) > 0 {
+			flow.AddAttr(attr+"_description", e.alias)
+		}
+		if e.speed > 0 {
+			flow.AddAttr(attr+"_speed", e.speed)
+		}
+	}
+}
+
+func (s *snmpInterface) Enrich(flow *public.Flow) {
+	ip := flow.AsIp("sampler")
+	if ip == nil {
+		return
+	}
+	sampler := ip.String()
+	s.enrichInterface(flow, sampler, "input_interface")
+	s.enrichInterface(flow, sampler, "output_interface")
+}
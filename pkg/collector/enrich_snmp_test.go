@@ -0,0 +1,197 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+// startSnmpResponder starts minimal SNMP v2c agent on loopback which answers GetNext/GetBulk requests
+// from provided static table. It returns UDP port the agent listens on.
+func startSnmpResponder(t *testing.T, table []gosnmp.SnmpPDU) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unable to listen on udp address: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	slices.SortFunc(table, func(a, b gosnmp.SnmpPDU) int {
+		return slices.Compare(oidParts(a.Name), oidParts(b.Name))
+	})
+	next := func(oid string, count int) []gosnmp.SnmpPDU {
+		out := make([]gosnmp.SnmpPDU, 0)
+		for _, pdu := range table {
+			if slices.Compare(oidParts(pdu.Name), oidParts(oid)) > 0 {
+				out = append(out, pdu)
+				if len(out) == count {
+					break
+				}
+			}
+		}
+		if len(out) == 0 {
+			out = append(out, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+		}
+		return out
+	}
+	go func() {
+		buf := make([]byte, 65535)
+		dec := &gosnmp.GoSNMP{}
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := dec.SnmpDecodePacket(buf[:n])
+			if err != nil || len(req.Variables) == 0 {
+				continue
+			}
+			resp := &gosnmp.SnmpPacket{
+				Version:   req.Version,
+				Community: req.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: req.RequestID,
+			}
+			switch req.PDUType {
+			case gosnmp.GetBulkRequest:
+				resp.Variables = next(req.Variables[0].Name, int(req.MaxRepetitions))
+			default:
+				resp.Variables = next(req.Variables[0].Name, 1)
+			}
+			out, err := resp.MarshalMsg()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(out, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func oidParts(oid string) []int {
+	out := make([]int, 0)
+	n := 0
+	for i := 1; i < len(oid); i++ {
+		if oid[i] == '.' {
+			out = append(out, n)
+			n = 0
+		} else {
+			n = n*10 + int(oid[i]-'0')
+		}
+	}
+	return append(out, n)
+}
+
+func TestSnmpInterface(t *testing.T) {
+	port := startSnmpResponder(t, []gosnmp.SnmpPDU{
+		{Name: oidIfName + ".1", Type: gosnmp.OctetString, Value: []byte("ether1")},
+		{Name: oidIfName + ".2", Type: gosnmp.OctetString, Value: []byte("ether2")},
+		{Name: oidIfAlias + ".1", Type: gosnmp.OctetString, Value: []byte("uplink")},
+		{Name: oidIfAlias + ".2", Type: gosnmp.OctetString, Value: []byte("")},
+		{Name: oidIfHighSpeed + ".1", Type: gosnmp.Gauge32, Value: uint32(1000)},
+		{Name: oidIfHighSpeed + ".2", Type: gosnmp.Gauge32, Value: uint32(100)},
+		{Name: ".1.3.6.1.2.1.31.1.1.1.19.1", Type: gosnmp.TimeTicks, Value: uint32(0)},
+	})
+	e := &snmpInterface{}
+	e.Configure(map[string]interface{}{
+		"port":      port,
+		"community": "public",
+		"timeout":   "1s",
+		"samplers":  []interface{}{"127.0.0.1"},
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	f := &public.Flow{}
+	f.AddAttr("sampler", []byte{127, 0, 0, 1})
+	f.AddAttr("input_interface", uint32(1))
+	f.AddAttr("output_interface", uint32(2))
+	assert.Eventually(t, func() bool {
+		e.Enrich(f)
+		return f.AsString("input_interface_name") != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, "ether1", *f.AsString("input_interface_name"))
+	assert.Equal(t, "uplink", *f.AsString("input_interface_description"))
+	assert.Equal(t, uint64(1_000_000_000), f.Raw("input_interface_speed"))
+	assert.Equal(t, "ether2", *f.AsString("output_interface_name"))
+	assert.Nil(t, f.Raw("output_interface_description"))
+	assert.Equal(t, uint64(100_000_000), f.Raw("output_interface_speed"))
+}
+
+func TestSnmpInterfaceUnknownSampler(t *testing.T) {
+	cfg := map[string]interface{}{
+		"interval": "1h",
+		"port":     getFreePort("udp", t),
+		"timeout":  "10ms",
+		"retries":  0,
+	}
+	flow := func(sampler byte) *public.Flow {
+		f := &public.Flow{}
+		f.AddAttr("sampler", []byte{127, 0, 0, sampler})
+		f.AddAttr("input_interface", uint32(1))
+		return f
+	}
+
+	// samplers that are not listed are not polled by default
+	e := &snmpInterface{}
+	e.Configure(cfg)
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+	f := flow(1)
+	e.Enrich(f)
+	assert.Nil(t, f.Raw("input_interface_name"))
+	e.mu.RLock()
+	assert.Empty(t, e.known)
+	e.mu.RUnlock()
+
+	cfg["discover"] = true
+	cfg["max_discovered"] = 2
+	e = &snmpInterface{}
+	e.Configure(cfg)
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+	for i := byte(1); i <= 3; i++ {
+		e.Enrich(flow(i))
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	assert.Equal(t, map[string]bool{"127.0.0.1": true, "127.0.0.2": true}, e.known)
+}
+
+func TestSnmpInterfaceConfigureInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		(&snmpInterface{}).Configure(map[string]interface{}{"version": "1"})
+	})
+	assert.Panics(t, func() {
+		(&snmpInterface{}).Configure(map[string]interface{}{"version": "3", "auth_protocol": "crc32"})
+	})
+	assert.PanicsWithValue(t, "interval (if specified) must be a positive Go duration string, e.g. 1h", func() {
+		(&snmpInterface{}).Configure(map[string]interface{}{"interval": "0s"})
+	})
+}
@@ -39,6 +39,38 @@ func TestInterfaceMapper(t *testing.T) {
 	assert.Equal(t, "eth0", *f.AsString("output_interface_name"))
 }
 
+func TestInterfaceMapperPerSampler(t *testing.T) {
+	e := getEnricher("interface_mapper")
+	assert.NoError(t, e.Start())
+	e.Configure(map[string]interface{}{
+		"default": map[string]interface{}{
+			"0": "wan0",
+			"4": "lan",
+		},
+		"10.0.0.1": map[string]interface{}{
+			"4": "ether4",
+		},
+	})
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+	f := &public.Flow{}
+	f.AddAttr("sampler", []byte{10, 0, 0, 1})
+	f.AddAttr("input_interface", uint32(0))
+	f.AddAttr("output_interface", uint32(4))
+	e.Enrich(f)
+	assert.Equal(t, "wan0", *f.AsString("input_interface_name"))
+	assert.Equal(t, "ether4", *f.AsString("output_interface_name"))
+
+	f = &public.Flow{}
+	f.AddAttr("sampler", []byte{10, 0, 0, 2})
+	f.AddAttr("input_interface", uint32(4))
+	f.AddAttr("output_interface", uint32(5))
+	e.Enrich(f)
+	assert.Equal(t, "lan", *f.AsString("input_interface_name"))
+	assert.Nil(t, f.AsString("output_interface_name"))
+}
+
 func TestProtocolName(t *testing.T) {
 	e := getEnricher("protocol_name")
 	assert.NoError(t, e.Start())
@@ -52,6 +84,57 @@ func TestProtocolName(t *testing.T) {
 	assert.Equal(t, "icmp", *f.AsString("proto_name"))
 }
 
+func TestProtocolNameRegistry(t *testing.T) {
+	e := &protocolName{}
+	e.Configure(map[string]interface{}{
+		"unknown_format": "proto-%d",
+		"overrides": map[string]interface{}{
+			"89": "ospf",
+		},
+	})
+	assert.NoError(t, e.Start())
+	for proto, name := range map[uint32]string{
+		6:   "tcp",
+		47:  "gre",
+		50:  "esp",
+		58:  "ipv6-icmp",
+		89:  "ospf",
+		132: "sctp",
+		200: "proto-200",
+	} {
+		f := &public.Flow{}
+		f.AddAttr("proto", proto)
+		e.Enrich(f)
+		assert.Equal(t, name, *f.AsString("proto_name"))
+	}
+	f := &public.Flow{}
+	e.Enrich(f)
+	assert.Equal(t, "unknown", *f.AsString("proto_name"))
+}
+
+func TestProtocolNameEmptyFormat(t *testing.T) {
+	e := getEnricher("protocol_name")
+	e.Configure(map[string]interface{}{
+		"unknown_format": "",
+		"on_missing":     "none",
+		"overrides": map[string]interface{}{
+			"200": "custom",
+		},
+	})
+	assert.NoError(t, e.Start())
+	f := &public.Flow{}
+	f.AddAttr("proto", uint32(200))
+	e.Enrich(f)
+	assert.Equal(t, "custom", *f.AsString("proto_name"))
+	f = &public.Flow{}
+	f.AddAttr("proto", uint32(201))
+	e.Enrich(f)
+	assert.Equal(t, "", *f.AsString("proto_name"))
+	f = &public.Flow{}
+	e.Enrich(f)
+	assert.Equal(t, "none", *f.AsString("proto_name"))
+}
+
 func TestReverseLookup(t *testing.T) {
 	f := &public.Flow{}
 
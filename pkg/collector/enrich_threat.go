@@ -0,0 +1,246 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+type threatList struct {
+	name    string
+	file    string
+	entries *prefixTrie[struct{}]
+}
+
+// threatIntel tags flows which have known-bad address on either side, based on blocklists.
+type threatIntel struct {
+	logger         *slog.Logger
+	reloadInterval time.Duration
+
+	mu     sync.RWMutex
+	lists  []*threatList
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	matchedBytes *prometheus.CounterVec
+	listEntries  *prometheus.GaugeVec
+	// returns sampling rate announced by exporter, zero if unknown
+	samplingRate func(sampler string) uint32
+}
+
+// setSamplingRate implements samplingAware, so that matched bytes agree with utilization
+func (t *threatIntel) setSamplingRate(fn func(sampler string) uint32) {
+	t.samplingRate = fn
+}
+
+func (t *threatIntel) Configure(cfg map[string]interface{}) {
+	t.reloadInterval = cfgPositiveDuration(cfg, "reload_interval", 10*time.Minute)
+	t.lists = nil
+	if l, ok := cfg["lists"]; ok {
+		items, ok := l.([]interface{})
+		if !ok {
+			panic("lists (if specified) must be a list")
+		}
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				panic("list must be a mapping with name and file keys")
+			}
+			tl := &threatList{
+				name: cfgString(m, "name", ""),
+				file: cfgString(m, "file", ""),
+			}
+			if len(tl.name) == 0 || len(tl.file) == 0 {
+				panic("list must have both name and file specified")
+			}
+			t.lists = append(t.lists, tl)
+		}
+	}
+}
+
+func (t *threatIntel) Start() error {
+	t.logger = baseLogger.With("component", "threat_intel")
+	t.matchedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "threat_intel",
+		Name:      "matched_bytes",
+		Help:      "The total number of bytes in flows matching blocklist, by list and side.",
+	}, []string{"list", "side"})
+	t.listEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "threat_intel",
+		Name:      "list_entries",
+		Help:      "The number of addresses and networks currently loaded from blocklist.",
+	}, []string{"list"})
+	for _, tl := range t.lists {
+		if err := t.reload(tl); err != nil {
+			return err
+		}
+	}
+	t.stopCh = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stopCh:
+				return
+			case <-ticker.C:
+				for _, tl := range t.lists {
+					if err := t.reload(tl); err != nil {
+						t.logger.Warn("unable to reload blocklist, keeping previous data", "list", tl.name, "err", err)
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (t *threatIntel) Close() error {
+	if t.stopCh != nil {
+		close(t.stopCh)
+		t.wg.Wait()
+		t.stopCh = nil
+	}
+	return nil
+}
+
+func (t *threatIntel) Describe(ch chan<- *prometheus.Desc) {
+	t.matchedBytes.Describe(ch)
+	t.listEntries.Describe(ch)
+}
+
+func (t *threatIntel) Collect(ch chan<- prometheus.Metric) {
+	t.matchedBytes.Collect(ch)
+	t.listEntries.Collect(ch)
+}
+
+func (t *threatIntel) reload(tl *threatList) error {
+	f, err := os.Open(tl.file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	entries, err := parseBlocklist(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tl.file, err)
+	}
+	t.mu.Lock()
+	tl.entries = entries
+	t.mu.Unlock()
+	t.listEntries.WithLabelValues(tl.name).Set(float64(entries.len()))
+	t.logger.Debug("blocklist loaded", "list", tl.name, "entries", entries.len())
+	return nil
+}
+
+// parseBlocklist parses list of addresses or networks, one per line. Comments starting with "#" (plain, FireHOL)
+// or ";" (Spamhaus DROP) are ignored, as well as anything after first field. Lines of Tor exit-addresses
+// format ("ExitAddress <ip> <date> <time>") are recognized too, other keywords of that format are skipped.
+func parseBlocklist(r io.Reader) (*prefixTrie[struct{}], error) {
+	entries := newPrefixTrie[struct{}]()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, _, _ = strings.Cut(line, ";")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		entry := fields[0]
+		switch {
+		case entry == "ExitAddress" && len(fields) > 1:
+			entry = fields[1]
+		case entry == "ExitNode" || entry == "Published" || entry == "LastStatus" || entry == "ExitAddress":
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid entry: %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			entries.insert(&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, struct{}{})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries.insert(n, struct{}{})
+	}
+	return entries, scanner.Err()
+}
+
+// match returns name of first list that contains given address
+func (t *threatIntel) match(ip net.IP) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, tl := range t.lists {
+		if tl.entries == nil {
+			continue
+		}
+		if _, _, ok := tl.entries.lookup(ip); ok {
+			return tl.name, true
+		}
+	}
+	return "", false
+}
+
+func (t *threatIntel) Enrich(flow *public.Flow) {
+	dstList, dstOk := t.match(flow.AsIp("destination_ip"))
+	srcList, srcOk := t.match(flow.AsIp("source_ip"))
+	var list, side string
+	switch {
+	case dstOk && srcOk:
+		list, side = dstList, "both"
+	case dstOk:
+		list, side = dstList, "destination"
+	case srcOk:
+		list, side = srcList, "source"
+	default:
+		return
+	}
+	flow.AddAttr("threat_list", list)
+	flow.AddAttr("threat_side", side)
+	if bytes, ok := flow.Raw("bytes").(uint64); ok {
+		// sampled flow stands for this many flows
+		if ip := flow.AsIp("sampler"); ip != nil && t.samplingRate != nil {
+			if rate := t.samplingRate(ip.String()); rate > 1 {
+				bytes *= uint64(rate)
+			}
+		}
+		t.matchedBytes.WithLabelValues(list, side).Add(float64(bytes))
+	}
+}
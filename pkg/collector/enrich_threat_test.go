@@ -0,0 +1,141 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func threatFlow(src, dst []byte, bytes uint64) *public.Flow {
+	f := &public.Flow{}
+	f.AddAttr("source_ip", src)
+	f.AddAttr("destination_ip", dst)
+	f.AddAttr("bytes", bytes)
+	return f
+}
+
+func TestThreatIntel(t *testing.T) {
+	dir := t.TempDir()
+	drop := filepath.Join(dir, "drop.txt")
+	tor := filepath.Join(dir, "exit-addresses")
+	firehol := filepath.Join(dir, "firehol_level1.netset")
+	assert.NoError(t, os.WriteFile(drop, []byte(`; Spamhaus DROP List 2026/10/19
+1.10.16.0/20 ; SBL256894
+2.56.192.0/22 ; SBL459831
+`), 0o600))
+	assert.NoError(t, os.WriteFile(tor, []byte(`ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E
+Published 2026-10-18 22:14:04
+LastStatus 2026-10-19 00:00:00
+ExitAddress 162.247.74.201 2026-10-19 00:06:45
+`), 0o600))
+	assert.NoError(t, os.WriteFile(firehol, []byte(`#
+# firehol_level1
+#
+1.10.16.0/20
+203.0.113.7
+`), 0o600))
+
+	e := getEnricher("threat_intel")
+	e.Configure(map[string]interface{}{
+		"lists": []interface{}{
+			map[string]interface{}{"name": "spamhaus_drop", "file": drop},
+			map[string]interface{}{"name": "tor_exit", "file": tor},
+			map[string]interface{}{"name": "firehol_level1", "file": firehol},
+		},
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+	ti := e.(*threatIntel)
+
+	f := threatFlow([]byte{192, 168, 0, 10}, []byte{1, 10, 20, 1}, 100)
+	e.Enrich(f)
+	// first matching list wins
+	assert.Equal(t, "spamhaus_drop", *f.AsString("threat_list"))
+	assert.Equal(t, "destination", *f.AsString("threat_side"))
+
+	f = threatFlow([]byte{162, 247, 74, 201}, []byte{192, 168, 0, 10}, 200)
+	e.Enrich(f)
+	assert.Equal(t, "tor_exit", *f.AsString("threat_list"))
+	assert.Equal(t, "source", *f.AsString("threat_side"))
+
+	f = threatFlow([]byte{162, 247, 74, 201}, []byte{203, 0, 113, 7}, 50)
+	e.Enrich(f)
+	assert.Equal(t, "firehol_level1", *f.AsString("threat_list"))
+	assert.Equal(t, "both", *f.AsString("threat_side"))
+
+	f = threatFlow([]byte{192, 168, 0, 10}, []byte{8, 8, 8, 8}, 50)
+	e.Enrich(f)
+	assert.Nil(t, f.AsString("threat_list"))
+
+	assert.NoError(t, testutil.CollectAndCompare(ti.matchedBytes, strings.NewReader(`
+# HELP threat_intel_matched_bytes The total number of bytes in flows matching blocklist, by list and side.
+# TYPE threat_intel_matched_bytes counter
+threat_intel_matched_bytes{list="firehol_level1",side="both"} 50
+threat_intel_matched_bytes{list="spamhaus_drop",side="destination"} 100
+threat_intel_matched_bytes{list="tor_exit",side="source"} 200
+`)))
+
+	// reload picks up new content, broken file keeps previous one
+	assert.NoError(t, os.WriteFile(tor, []byte("ExitAddress 8.8.4.4 2026-10-19 00:06:45\n"), 0o600))
+	assert.NoError(t, ti.reload(ti.lists[1]))
+	assert.NoError(t, os.WriteFile(firehol, []byte("not-an-address\n"), 0o600))
+	assert.Error(t, ti.reload(ti.lists[2]))
+
+	f = threatFlow([]byte{8, 8, 4, 4}, []byte{203, 0, 113, 7}, 1)
+	e.Enrich(f)
+	assert.Equal(t, "firehol_level1", *f.AsString("threat_list"))
+	assert.Equal(t, "both", *f.AsString("threat_side"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(ti.listEntries.WithLabelValues("tor_exit")))
+}
+
+func TestThreatIntelSamplingRate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "list.txt")
+	assert.NoError(t, os.WriteFile(list, []byte("203.0.113.7\n"), 0o600))
+	e := getEnricher("threat_intel")
+	e.Configure(map[string]interface{}{
+		"lists": []interface{}{
+			map[string]interface{}{"name": "local", "file": list},
+		},
+	})
+	ti := e.(*threatIntel)
+	ti.setSamplingRate(func(sampler string) uint32 {
+		if sampler == "10.0.0.1" {
+			return 100
+		}
+		return 0
+	})
+	assert.NoError(t, e.Start())
+	defer func(e public.Enricher) {
+		_ = e.Close()
+	}(e)
+
+	// sampled flow is scaled, flow from exporter without sampling is not
+	f := threatFlow([]byte{192, 168, 0, 10}, []byte{203, 0, 113, 7}, 10)
+	f.AddAttr("sampler", []byte{10, 0, 0, 1})
+	e.Enrich(f)
+	f = threatFlow([]byte{192, 168, 0, 10}, []byte{203, 0, 113, 7}, 5)
+	f.AddAttr("sampler", []byte{10, 0, 0, 2})
+	e.Enrich(f)
+	assert.Equal(t, float64(1005), testutil.ToFloat64(ti.matchedBytes.WithLabelValues("local", "destination")))
+}
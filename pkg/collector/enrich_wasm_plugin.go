@@ -0,0 +1,264 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// ABI of WASM plugin. Plugin module must export:
+//   - "memory"
+//   - "alloc(size i32) -> i32", which returns pointer to buffer of given size in plugin's memory
+//   - "enrich(ptr i32, len i32) -> i64", which receives JSON object with flow attributes and returns
+//     pointer (upper 32 bits) and length (lower 32 bits) of JSON object with attributes to add, or 0 if there are none
+//
+// Optionally, it can export "init(ptr i32, len i32) -> i32", which receives JSON object with plugin configuration
+// and returns 0 on success. Plugin can import "log(ptr i32, len i32)" from "ipfix" module to write into collector's log.
+// WASI is available, but without access to file system, network or environment.
+//
+// Plugin that allocates buffers on heap should also export "dealloc(ptr i32, len i32)". It is called with every buffer
+// obtained from alloc once the call that received it returns, and with result of enrich once it is read. Without it,
+// plugin has to reuse its buffers, otherwise it runs out of memory.
+const (
+	wasmHostModule = "ipfix"
+	wasmFnAlloc    = "alloc"
+	wasmFnDealloc  = "dealloc"
+	wasmFnEnrich   = "enrich"
+	wasmFnInit     = "init"
+)
+
+type wasmInstance struct {
+	mod    api.Module
+	alloc  api.Function
+	enrich api.Function
+	// optional
+	dealloc api.Function
+}
+
+// wasmPlugin runs flows through enrich function of WASM module. Since module instance is not safe for concurrent use,
+// pool of instances is kept.
+type wasmPlugin struct {
+	logger    *slog.Logger
+	file      string
+	config    map[string]interface{}
+	timeout   time.Duration
+	instances int
+	maxMemory int
+
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	// idle instances, nil value means instance has to be created
+	pool chan *wasmInstance
+}
+
+func (w *wasmPlugin) Configure(cfg map[string]interface{}) (err error) {
+	defer cfgRecover(&err)
+	w.file = cfgString(cfg, "file", "")
+	w.timeout = cfgPositiveDuration(cfg, "timeout", 10*time.Millisecond)
+	w.instances = cfgInt(cfg, "instances", 2)
+	w.maxMemory = cfgInt(cfg, "max_memory", 16)
+	w.config = map[string]interface{}{}
+	if c, ok := cfg["config"]; ok {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			return errors.New("config (if specified) must be a mapping")
+		}
+		w.config = m
+	}
+	if w.instances < 1 {
+		return errors.New("instances (if specified) must be a positive integer")
+	}
+	if w.maxMemory < 1 {
+		return errors.New("max_memory (if specified) must be a positive integer")
+	}
+	return nil
+}
+
+func (w *wasmPlugin) Start(ctx context.Context) (err error) {
+	w.logger = baseLogger.With("component", "wasm")
+	if len(w.file) == 0 {
+		return fmt.Errorf("file with WASM module must be specified")
+	}
+	code, err := os.ReadFile(w.file)
+	if err != nil {
+		return err
+	}
+	// memory is limited in pages of 64KiB
+	w.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(w.maxMemory*16)))
+	defer func() {
+		if err != nil {
+			_ = w.runtime.Close(ctx)
+		}
+	}()
+	if _, err = wasi_snapshot_preview1.Instantiate(ctx, w.runtime); err != nil {
+		return err
+	}
+	if _, err = w.runtime.NewHostModuleBuilder(wasmHostModule).
+		NewFunctionBuilder().WithFunc(w.hostLog).Export("log").
+		Instantiate(ctx); err != nil {
+		return err
+	}
+	if w.compiled, err = w.runtime.CompileModule(ctx, code); err != nil {
+		return fmt.Errorf("%s: %w", w.file, err)
+	}
+	w.pool = make(chan *wasmInstance, w.instances)
+	// first instance is created eagerly, so that ABI problems are reported early
+	inst, err := w.newInstance(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", w.file, err)
+	}
+	w.pool <- inst
+	for i := 1; i < w.instances; i++ {
+		w.pool <- nil
+	}
+	return nil
+}
+
+func (w *wasmPlugin) Close() error {
+	if w.runtime != nil {
+		return w.runtime.Close(context.Background())
+	}
+	return nil
+}
+
+func (w *wasmPlugin) hostLog(_ context.Context, m api.Module, ptr, length uint32) {
+	if msg, ok := m.Memory().Read(ptr, length); ok {
+		w.logger.Info(string(msg), "module", w.file)
+	}
+}
+
+// call writes data into instance's memory and calls function with pointer to it and its length
+func (inst *wasmInstance) call(ctx context.Context, fn api.Function, data []byte) (uint64, error) {
+	res, err := inst.alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(res[0])
+	if !inst.mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("buffer returned by %s is out of memory range", wasmFnAlloc)
+	}
+	res, err = fn.Call(ctx, uint64(ptr), uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	if err = inst.free(ctx, ptr, uint32(len(data))); err != nil {
+		return 0, err
+	}
+	return res[0], nil
+}
+
+// free releases buffer in instance's memory, if plugin exports dealloc function
+func (inst *wasmInstance) free(ctx context.Context, ptr, length uint32) error {
+	if inst.dealloc == nil {
+		return nil
+	}
+	_, err := inst.dealloc.Call(ctx, uint64(ptr), uint64(length))
+	return err
+}
+
+func (w *wasmPlugin) newInstance(ctx context.Context) (*wasmInstance, error) {
+	mod, err := w.runtime.InstantiateModule(ctx, w.compiled, wazero.NewModuleConfig().
+		WithName("").
+		// reactor modules (e.g. Go with -buildmode=c-shared) need to be initialized, missing functions are skipped
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, err
+	}
+	inst := &wasmInstance{
+		mod:     mod,
+		alloc:   mod.ExportedFunction(wasmFnAlloc),
+		enrich:  mod.ExportedFunction(wasmFnEnrich),
+		dealloc: mod.ExportedFunction(wasmFnDealloc),
+	}
+	if inst.alloc == nil || inst.enrich == nil || mod.Memory() == nil {
+		_ = mod.Close(ctx)
+		return nil, fmt.Errorf("module must export memory, %s and %s functions", wasmFnAlloc, wasmFnEnrich)
+	}
+	if initFn := mod.ExportedFunction(wasmFnInit); initFn != nil {
+		data, err := json.Marshal(w.config)
+		if err != nil {
+			_ = mod.Close(ctx)
+			return nil, err
+		}
+		rc, err := inst.call(ctx, initFn, data)
+		if err == nil && uint32(rc) != 0 {
+			err = fmt.Errorf("%s returned %d", wasmFnInit, uint32(rc))
+		}
+		if err != nil {
+			_ = mod.Close(ctx)
+			return nil, err
+		}
+	}
+	return inst, nil
+}
+
+func (w *wasmPlugin) Enrich(flow *public.Flow) error {
+	data, err := json.Marshal(encodeFlowAttrs(flow))
+	if err != nil {
+		return err
+	}
+	inst := <-w.pool
+	defer func() {
+		w.pool <- inst
+	}()
+	if inst == nil {
+		if inst, err = w.newInstance(context.Background()); err != nil {
+			return fmt.Errorf("unable to create instance of %s: %w", w.file, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+	packed, err := inst.call(ctx, inst.enrich, data)
+	if err != nil {
+		// instance can be in inconsistent state (or closed after timeout), new one is created on next use
+		_ = inst.mod.Close(context.Background())
+		inst = nil
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s exceeded time limit of %v: %w", wasmFnEnrich, w.timeout, err)
+		}
+		return err
+	}
+	if packed == 0 {
+		return nil
+	}
+	out, ok := inst.mod.Memory().Read(uint32(packed>>32), uint32(packed))
+	if !ok {
+		return fmt.Errorf("result of %s is out of memory range", wasmFnEnrich)
+	}
+	var attrs map[string]interface{}
+	decodeErr := json.Unmarshal(out, &attrs)
+	if err = inst.free(ctx, uint32(packed>>32), uint32(packed)); err != nil {
+		_ = inst.mod.Close(context.Background())
+		inst = nil
+		return err
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("unable to decode result of %s: %w", wasmFnEnrich, decodeErr)
+	}
+	return mergeFlowAttrs(flow, attrs)
+}
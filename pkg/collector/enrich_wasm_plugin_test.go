@@ -0,0 +1,244 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func wasmUleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func wasmSleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func wasmVec(items ...[]byte) []byte {
+	b := wasmUleb(uint64(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func wasmName(s string) []byte {
+	return append(wasmUleb(uint64(len(s))), s...)
+}
+
+func wasmSection(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, wasmUleb(uint64(len(payload)))...), payload...)
+}
+
+func wasmCode(expr ...[]byte) []byte {
+	body := []byte{0x00} // no locals
+	for _, e := range expr {
+		body = append(body, e...)
+	}
+	return append(wasmUleb(uint64(len(body))), body...)
+}
+
+// testWasmModule assembles plugin which returns fixed attributes. To exercise limits, enrich loops forever
+// when input is longer than 10000 bytes and init fails when configuration is longer than 100 bytes.
+// Configuration is written to log using host function.
+func testWasmModule(output string) []byte {
+	return assembleTestWasmModule(output, false)
+}
+
+// testWasmModuleWithDealloc is like testWasmModule, but it also exports dealloc function,
+// which counts its calls in exported global "freed"
+func testWasmModuleWithDealloc(output string) []byte {
+	return assembleTestWasmModule(output, true)
+}
+
+func assembleTestWasmModule(output string, dealloc bool) []byte {
+	const outPtr = 16
+	i32, i64 := byte(0x7f), byte(0x7e)
+	m := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	m = append(m, wasmSection(1, wasmVec(
+		[]byte{0x60, 1, i32, 1, i32},      // 0: alloc
+		[]byte{0x60, 2, i32, i32, 1, i64}, // 1: enrich
+		[]byte{0x60, 2, i32, i32, 1, i32}, // 2: init
+		[]byte{0x60, 2, i32, i32, 0},      // 3: log, dealloc
+	))...)
+	m = append(m, wasmSection(2, wasmVec(
+		append(append(wasmName("ipfix"), wasmName("log")...), 0x00, 3),
+	))...)
+	funcs := [][]byte{{0}, {1}, {2}}
+	exports := [][]byte{
+		append(wasmName("memory"), 0x02, 0),
+		append(wasmName("alloc"), 0x00, 1),
+		append(wasmName("enrich"), 0x00, 2),
+		append(wasmName("init"), 0x00, 3),
+	}
+	code := [][]byte{
+		// alloc: return 1024
+		wasmCode([]byte{0x41}, wasmSleb(1024), []byte{0x0b}),
+		// enrich: if len > 10000 { loop forever }; return outPtr << 32 | len(output)
+		wasmCode([]byte{0x20, 1, 0x41}, wasmSleb(10000),
+			[]byte{0x4b, 0x04, 0x40, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b, 0x42},
+			wasmSleb(outPtr<<32|int64(len(output))), []byte{0x0b}),
+		// init: log(ptr, len); return len > 100
+		wasmCode([]byte{0x20, 0, 0x20, 1, 0x10, 0, 0x20, 1, 0x41}, wasmSleb(100), []byte{0x4b, 0x0b}),
+	}
+	if dealloc {
+		funcs = append(funcs, []byte{3})
+		exports = append(exports,
+			append(wasmName("dealloc"), 0x00, 4),
+			append(wasmName("freed"), 0x03, 0),
+		)
+		// dealloc: freed++
+		code = append(code, wasmCode([]byte{0x23, 0, 0x41, 1, 0x6a, 0x24, 0, 0x0b}))
+	}
+	m = append(m, wasmSection(3, wasmVec(funcs...))...)
+	m = append(m, wasmSection(5, wasmVec([]byte{0x00, 1}))...)
+	if dealloc {
+		m = append(m, wasmSection(6, wasmVec([]byte{i32, 0x01, 0x41, 0, 0x0b}))...)
+	}
+	m = append(m, wasmSection(7, wasmVec(exports...))...)
+	m = append(m, wasmSection(10, wasmVec(code...))...)
+	m = append(m, wasmSection(11, wasmVec(
+		append([]byte{0x00, 0x41, outPtr, 0x0b}, wasmName(output)...),
+	))...)
+	return m
+}
+
+func writeWasmModule(t *testing.T, code []byte) string {
+	file := filepath.Join(t.TempDir(), "plugin.wasm")
+	assert.NoError(t, os.WriteFile(file, code, 0o600))
+	return file
+}
+
+func TestWasmPlugin(t *testing.T) {
+	file := writeWasmModule(t, testWasmModule(`{"classification":"internal","risk":3,"bytes":"x"}`))
+	e, _ := newEnricher("wasm")
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"file":      file,
+		"instances": 2,
+		"timeout":   "100ms",
+		"config": map[string]interface{}{
+			"level": "strict",
+		},
+	}))
+	assert.NoError(t, e.Start(context.Background()))
+	defer func(e public.EnricherV2) {
+		_ = e.Close()
+	}(e)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f := scriptFlow()
+			assert.EqualError(t, e.Enrich(f), "attributes rejected because of their type: bytes")
+			assert.Equal(t, "internal", *f.AsString("classification"))
+			assert.Equal(t, uint32(3), *f.AsUint32("risk"))
+			assert.Equal(t, uint64(1500), f.Raw("bytes"))
+		}()
+	}
+	wg.Wait()
+
+	// runaway plugin is interrupted and replaced with fresh instance
+	f := scriptFlow()
+	f.AddAttr("payload", strings.Repeat("x", 20000))
+	assert.ErrorContains(t, e.Enrich(f), "exceeded time limit of 100ms")
+	assert.Nil(t, f.Raw("classification"))
+	for i := 0; i < 3; i++ {
+		f = scriptFlow()
+		assert.Error(t, e.Enrich(f))
+		assert.Equal(t, "internal", *f.AsString("classification"))
+	}
+}
+
+func TestWasmPluginDealloc(t *testing.T) {
+	_, impl := newEnricher("wasm")
+	w := impl.(*wasmPlugin)
+	assert.NoError(t, w.Configure(map[string]interface{}{
+		"file":      writeWasmModule(t, testWasmModuleWithDealloc(`{"classification":"internal"}`)),
+		"instances": 1,
+	}))
+	assert.NoError(t, w.Start(context.Background()))
+	defer func(e public.EnricherV2) {
+		_ = e.Close()
+	}(w)
+	for i := 0; i < 5; i++ {
+		f := scriptFlow()
+		assert.NoError(t, w.Enrich(f))
+		assert.Equal(t, "internal", *f.AsString("classification"))
+	}
+	inst := <-w.pool
+	defer func() {
+		w.pool <- inst
+	}()
+	// configuration passed to init, then input and output of every enrich call
+	assert.Equal(t, uint64(1+5*2), inst.mod.ExportedGlobal("freed").Get())
+}
+
+func TestWasmPluginInvalid(t *testing.T) {
+	// init rejects configuration
+	e := &wasmPlugin{}
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"file": writeWasmModule(t, testWasmModule(`{}`)),
+		"config": map[string]interface{}{
+			"long": strings.Repeat("x", 200),
+		},
+	}))
+	assert.ErrorContains(t, e.Start(context.Background()), "init returned 1")
+
+	// module without required exports
+	e = &wasmPlugin{}
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"file": writeWasmModule(t, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}),
+	}))
+	assert.ErrorContains(t, e.Start(context.Background()), "must export")
+
+	e = &wasmPlugin{}
+	assert.NoError(t, e.Configure(map[string]interface{}{
+		"file": writeWasmModule(t, []byte("not a module")),
+	}))
+	assert.Error(t, e.Start(context.Background()))
+
+	assert.Error(t, (&wasmPlugin{}).Start(context.Background()))
+	assert.EqualError(t, (&wasmPlugin{}).Configure(map[string]interface{}{"instances": 0}),
+		"instances (if specified) must be a positive integer")
+}
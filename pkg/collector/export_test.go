@@ -0,0 +1,36 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import "github.com/rkosegi/ipfix-collector/pkg/public"
+
+// getEnricher creates new instance of enricher registered under given name, or returns nil if there is none
+// or it implements public.EnricherV2 only.
+func getEnricher(name string) public.Enricher {
+	registryLock.RLock()
+	entry, ok := registry[name]
+	registryLock.RUnlock()
+	if !ok || entry.v1 == nil {
+		return nil
+	}
+	return entry.v1()
+}
+
+// unregisterEnricher removes enricher from registry
+func unregisterEnricher(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, name)
+}
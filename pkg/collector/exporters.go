@@ -0,0 +1,351 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/decoders/netflowlegacy"
+	"github.com/netsampler/goflow2/v2/decoders/sflow"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+const (
+	// packet rate of exporter is computed over window of this length
+	exporterRateWindow = time.Minute
+	// default retention of exporter, as multiple of silent_after
+	exporterRetentionFactor = 12
+	// how often are stale exporters looked for when packets arrive
+	exporterEvictInterval = time.Minute
+)
+
+type exporterState struct {
+	protocols    []string
+	firstSeen    time.Time
+	lastSeen     time.Time
+	packets      uint64
+	bytes        uint64
+	samplingRate uint32
+	decodeErrors map[string]uint64
+	queueDrops   uint64
+
+	windowStart   time.Time
+	windowPackets uint64
+	// packet rate computed over last complete window
+	packetRate float64
+}
+
+// exporterStatus is entry of exporter inventory, as returned by HTTP endpoint
+type exporterStatus struct {
+	Address    string    `json:"address"`
+	Protocols  []string  `json:"protocols"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Silent     bool      `json:"silent"`
+	Packets    uint64    `json:"packets"`
+	Bytes      uint64    `json:"bytes"`
+	PacketRate float64   `json:"packet_rate"`
+	// zero if exporter does not announce it in header or samples
+	SamplingRate uint32            `json:"sampling_rate"`
+	Templates    int               `json:"templates"`
+	DecodeErrors map[string]uint64 `json:"decode_errors"`
+	QueueDrops   uint64            `json:"queue_drops"`
+}
+
+// exporterInventory keeps track of every exporter that sent packets to collector
+type exporterInventory struct {
+	silentAfter time.Duration
+	// exporters that did not send anything for this long are removed
+	retention  time.Duration
+	now        func() time.Time
+	silentDesc *prometheus.Desc
+
+	mu        sync.Mutex
+	exporters map[string]*exporterState
+	lastEvict time.Time
+	// returns templates of NetFlow v9 and IPFIX, keyed by source address and port
+	templates func() map[string]map[uint64]interface{}
+}
+
+func newExporterInventory(prefix string, spec *public.ExportersSpec) (*exporterInventory, error) {
+	silentAfter := 5 * time.Minute
+	if spec != nil && len(spec.SilentAfter) > 0 {
+		var err error
+		if silentAfter, err = time.ParseDuration(spec.SilentAfter); err != nil {
+			return nil, err
+		}
+	}
+	retention := exporterRetentionFactor * silentAfter
+	if spec != nil && len(spec.Retention) > 0 {
+		var err error
+		if retention, err = time.ParseDuration(spec.Retention); err != nil {
+			return nil, err
+		}
+		if retention <= silentAfter {
+			return nil, fmt.Errorf("retention (if specified) must be longer than silent_after (%v)", silentAfter)
+		}
+	}
+	return &exporterInventory{
+		silentAfter: silentAfter,
+		retention:   retention,
+		now:         time.Now,
+		exporters:   map[string]*exporterState{},
+		silentDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(prefix, "receiver", "exporter_silent"),
+			"Whether exporter did not send any packet for longer than configured threshold (1) or not (0)",
+			[]string{"sampler"}, nil),
+	}, nil
+}
+
+func (e *exporterInventory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.silentDesc
+}
+
+func (e *exporterInventory) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range e.status() {
+		v := 0.0
+		if s.Silent {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(e.silentDesc, prometheus.GaugeValue, v, s.Address)
+	}
+}
+
+// setTemplateSource sets function that provides templates received from exporters
+func (e *exporterInventory) setTemplateSource(fn func() map[string]map[uint64]interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.templates = fn
+}
+
+// get returns state of exporter, creating it if needed. Lock must be held.
+func (e *exporterInventory) get(sampler string) *exporterState {
+	s, ok := e.exporters[sampler]
+	if !ok {
+		now := e.now()
+		s = &exporterState{firstSeen: now, windowStart: now, decodeErrors: map[string]uint64{}}
+		e.exporters[sampler] = s
+	}
+	return s
+}
+
+// packet records export packet received from exporter
+func (e *exporterInventory) packet(sampler, protocol string, size int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.get(sampler)
+	now := e.now()
+	s.lastSeen = now
+	s.packets++
+	s.bytes += uint64(size)
+	if !slices.Contains(s.protocols, protocol) {
+		s.protocols = append(s.protocols, protocol)
+		slices.Sort(s.protocols)
+	}
+	if elapsed := now.Sub(s.windowStart); elapsed >= exporterRateWindow {
+		s.packetRate = float64(s.windowPackets) / elapsed.Seconds()
+		s.windowStart = now
+		s.windowPackets = 0
+	}
+	s.windowPackets++
+	if now.Sub(e.lastEvict) >= exporterEvictInterval {
+		e.evict(now)
+	}
+}
+
+// evict removes exporters that were not seen for longer than retention. Lock must be held.
+func (e *exporterInventory) evict(now time.Time) {
+	e.lastEvict = now
+	for addr, s := range e.exporters {
+		if now.Sub(s.lastSeen) > e.retention {
+			delete(e.exporters, addr)
+		}
+	}
+}
+
+func (e *exporterInventory) decodeError(sampler, typ string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.get(sampler).decodeErrors[typ]++
+}
+
+func (e *exporterInventory) queueDrop(sampler string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.get(sampler)
+	s.lastSeen = e.now()
+	s.queueDrops++
+}
+
+// observePacket records sampling rate announced in decoded export packet
+func (e *exporterInventory) observePacket(sampler string, msg any) {
+	var rate uint32
+	switch pkt := msg.(type) {
+	case *netflowlegacy.PacketNetFlowV5:
+		// upper 2 bits are sampling mode
+		rate = uint32(pkt.SamplingInterval & 0x3fff)
+	case *sflow.Packet:
+		for _, sample := range pkt.Samples {
+			switch x := sample.(type) {
+			case sflow.FlowSample:
+				rate = x.SamplingRate
+			case sflow.ExpandedFlowSample:
+				rate = x.SamplingRate
+			}
+		}
+	case *netflow.NFv9Packet:
+		rate = flowSetsSamplingRate(pkt.FlowSets)
+	case *netflow.IPFIXPacket:
+		rate = flowSetsSamplingRate(pkt.FlowSets)
+	}
+	if rate == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.get(sampler).samplingRate = rate
+}
+
+// flowSetsSamplingRate finds sampling rate in records of NetFlow v9 or IPFIX packet. Exporters usually announce it
+// in options data, some of them in every data record.
+func flowSetsSamplingRate(flowSets []interface{}) uint32 {
+	var rate uint32
+	for _, fs := range flowSets {
+		switch x := fs.(type) {
+		case netflow.OptionsDataFlowSet:
+			for _, r := range x.Records {
+				if v := recordSamplingRate(r.OptionsValues); v > 0 {
+					rate = v
+				}
+			}
+		case netflow.DataFlowSet:
+			for _, r := range x.Records {
+				if v := recordSamplingRate(r.Values); v > 0 {
+					rate = v
+				}
+			}
+		}
+	}
+	return rate
+}
+
+// recordSamplingRate gets sampling rate from samplingInterval (34), FLOW_SAMPLER_RANDOM_INTERVAL (50)
+// or samplingPacketInterval (305) field. When samplingPacketSpace (306) is present too, every interval
+// of selected packets is followed by space of skipped ones (RFC 5477).
+func recordSamplingRate(fields []netflow.DataField) uint32 {
+	var rate, interval, space uint32
+	for _, f := range fields {
+		if f.PenProvided {
+			continue
+		}
+		b, ok := f.Value.([]byte)
+		if !ok || len(b) == 0 || len(b) > 4 {
+			continue
+		}
+		var v uint32
+		for _, x := range b {
+			v = v<<8 | uint32(x)
+		}
+		switch f.Type {
+		case netflow.NFV9_FIELD_SAMPLING_INTERVAL, netflow.NFV9_FIELD_FLOW_SAMPLER_RANDOM_INTERVAL:
+			rate = v
+		case netflow.IPFIX_FIELD_samplingPacketInterval:
+			interval = v
+		case netflow.IPFIX_FIELD_samplingPacketSpace:
+			space = v
+		}
+	}
+	if rate == 0 && interval > 0 {
+		if space > 0 {
+			return (interval + space) / interval
+		}
+		return interval
+	}
+	return rate
+}
+
+// samplingRate returns sampling rate last announced by exporter, or zero if it is not known
+func (e *exporterInventory) samplingRate(sampler string) uint32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s, ok := e.exporters[sampler]; ok {
+		return s.samplingRate
+	}
+	return 0
+}
+
+// status returns current state of all exporters, sorted by address.
+// Exporters that were not seen for longer than retention are removed first.
+func (e *exporterInventory) status() []*exporterStatus {
+	e.mu.Lock()
+	source := e.templates
+	e.mu.Unlock()
+	templates := map[string]int{}
+	if source != nil {
+		for src, t := range source() {
+			if ap, err := netip.ParseAddrPort(src); err == nil {
+				templates[ap.Addr().Unmap().String()] += len(t)
+			}
+		}
+	}
+	e.mu.Lock()
+	now := e.now()
+	e.evict(now)
+	ret := make([]*exporterStatus, 0, len(e.exporters))
+	for addr, s := range e.exporters {
+		rate := s.packetRate
+		// exporter that did not send anything for whole window is idle
+		if now.Sub(s.lastSeen) >= exporterRateWindow {
+			rate = 0
+		}
+		errs := make(map[string]uint64, len(s.decodeErrors))
+		for k, v := range s.decodeErrors {
+			errs[k] = v
+		}
+		ret = append(ret, &exporterStatus{
+			Address:      addr,
+			Protocols:    append([]string{}, s.protocols...),
+			FirstSeen:    s.firstSeen,
+			LastSeen:     s.lastSeen,
+			Silent:       now.Sub(s.lastSeen) > e.silentAfter,
+			Packets:      s.packets,
+			Bytes:        s.bytes,
+			PacketRate:   rate,
+			SamplingRate: s.samplingRate,
+			Templates:    templates[addr],
+			DecodeErrors: errs,
+			QueueDrops:   s.queueDrops,
+		})
+	}
+	e.mu.Unlock()
+	slices.SortFunc(ret, func(a, b *exporterStatus) int {
+		return netip.MustParseAddr(a.Address).Compare(netip.MustParseAddr(b.Address))
+	})
+	return ret
+}
+
+// ServeHTTP lists all known exporters as JSON
+func (e *exporterInventory) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e.status())
+}
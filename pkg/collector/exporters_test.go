@@ -0,0 +1,209 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+// netflowV9TemplatePacket builds NetFlow v9 packet with single template of one field
+func netflowV9TemplatePacket(seq uint32) []byte {
+	b := make([]byte, 32)
+	binary.BigEndian.PutUint16(b[0:], 9)
+	binary.BigEndian.PutUint16(b[2:], 1)
+	binary.BigEndian.PutUint32(b[12:], seq)
+	// template flowset: id, length, template id, field count, field type and length
+	binary.BigEndian.PutUint16(b[20:], 0)
+	binary.BigEndian.PutUint16(b[22:], 12)
+	binary.BigEndian.PutUint16(b[24:], 256)
+	binary.BigEndian.PutUint16(b[26:], 1)
+	binary.BigEndian.PutUint16(b[28:], 8)
+	binary.BigEndian.PutUint16(b[30:], 4)
+	return b
+}
+
+func TestExporterInventory(t *testing.T) {
+	_, err := newExporterInventory("", &public.ExportersSpec{SilentAfter: "soon"})
+	assert.Error(t, err)
+
+	inventory, err := newExporterInventory("", &public.ExportersSpec{SilentAfter: "2m"})
+	assert.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	inventory.now = func() time.Time {
+		return now
+	}
+	r := newReceiverMetrics("", baseLogger, inventory)
+	pipe := utils.NewFlowPipe(&utils.PipeConfig{
+		Producer: &producerMetricAdapter{consumer: &countingConsumer{}, receiver: r},
+	})
+	inventory.setTemplateSource(pipe.NetFlowPipe.GetTemplatesForAllSources)
+	decode := r.wrap(pipe.DecodeFlow)
+
+	v5 := netflowV5Packet(0, 1)
+	// sampling mode 1, interval 100
+	binary.BigEndian.PutUint16(v5[22:], 0x4064)
+	assert.NoError(t, decode(exportMessage("10.0.0.1:5000", v5)))
+	assert.NoError(t, decode(exportMessage("10.0.0.2:2055", netflowV9TemplatePacket(0))))
+	assert.Error(t, decode(exportMessage("10.0.0.2:2055", netflowV9Packet(1)[:4])))
+	r.Dropped(*exportMessage("10.0.0.3:5000", v5))
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, decode(exportMessage("10.0.0.1:5000", netflowV5Packet(1, 1))))
+	now = now.Add(90 * time.Second)
+
+	rec := httptest.NewRecorder()
+	inventory.ServeHTTP(rec, httptest.NewRequest("GET", "/exporters", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var list []*exporterStatus
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, 3, len(list))
+
+	assert.Equal(t, "10.0.0.1", list[0].Address)
+	assert.Equal(t, []string{"netflow_v5"}, list[0].Protocols)
+	assert.Equal(t, uint64(2), list[0].Packets)
+	assert.Equal(t, uint64(2*(24+48)), list[0].Bytes)
+	assert.Equal(t, uint32(100), list[0].SamplingRate)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), list[0].FirstSeen)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC), list[0].LastSeen)
+	// nothing was received for whole window
+	assert.Equal(t, 0.0, list[0].PacketRate)
+	assert.False(t, list[0].Silent)
+
+	assert.Equal(t, "10.0.0.2", list[1].Address)
+	assert.Equal(t, []string{"netflow_v9"}, list[1].Protocols)
+	assert.Equal(t, 1, list[1].Templates)
+	assert.Equal(t, map[string]uint64{"malformed": 1}, list[1].DecodeErrors)
+	assert.True(t, list[1].Silent)
+
+	assert.Equal(t, "10.0.0.3", list[2].Address)
+	assert.Equal(t, uint64(1), list[2].QueueDrops)
+	assert.Equal(t, uint64(0), list[2].Packets)
+
+	assert.NoError(t, testutil.CollectAndCompare(inventory, strings.NewReader(`
+# HELP receiver_exporter_silent Whether exporter did not send any packet for longer than configured threshold (1) or not (0)
+# TYPE receiver_exporter_silent gauge
+receiver_exporter_silent{sampler="10.0.0.1"} 0
+receiver_exporter_silent{sampler="10.0.0.2"} 1
+receiver_exporter_silent{sampler="10.0.0.3"} 1
+`)))
+}
+
+func TestExporterPacketRate(t *testing.T) {
+	inventory, err := newExporterInventory("", nil)
+	assert.NoError(t, err)
+	now := time.Now()
+	inventory.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < 120; i++ {
+		inventory.packet("10.0.0.1", "netflow_v5", 100)
+		now = now.Add(500 * time.Millisecond)
+	}
+	// first complete window had 120 packets in 60 seconds
+	inventory.packet("10.0.0.1", "netflow_v5", 100)
+	assert.Equal(t, 2.0, inventory.status()[0].PacketRate)
+}
+
+func TestExporterSamplingRate(t *testing.T) {
+	inventory, err := newExporterInventory("", nil)
+	assert.NoError(t, err)
+	field := func(typ uint16, value ...byte) netflow.DataField {
+		return netflow.DataField{Type: typ, Value: value}
+	}
+	// NetFlow v9 announces sampling interval in options data
+	inventory.observePacket("10.0.0.1", &netflow.NFv9Packet{FlowSets: []interface{}{
+		netflow.OptionsDataFlowSet{Records: []netflow.OptionsDataRecord{{
+			ScopesValues:  []netflow.DataField{field(1, 0, 0, 0, 1)},
+			OptionsValues: []netflow.DataField{field(netflow.NFV9_FIELD_SAMPLING_INTERVAL, 0, 0, 3, 232)},
+		}}},
+	}})
+	assert.Equal(t, uint32(1000), inventory.samplingRate("10.0.0.1"))
+
+	// IPFIX exporter selects 1 packet out of every 512
+	inventory.observePacket("10.0.0.2", &netflow.IPFIXPacket{FlowSets: []interface{}{
+		netflow.DataFlowSet{Records: []netflow.DataRecord{{
+			Values: []netflow.DataField{
+				field(netflow.IPFIX_FIELD_samplingPacketInterval, 0, 0, 0, 1),
+				field(netflow.IPFIX_FIELD_samplingPacketSpace, 0, 0, 1, 255),
+			},
+		}}},
+	}})
+	assert.Equal(t, uint32(512), inventory.samplingRate("10.0.0.2"))
+
+	// packet without sampling information keeps rate announced before
+	inventory.observePacket("10.0.0.2", &netflow.IPFIXPacket{})
+	assert.Equal(t, uint32(512), inventory.samplingRate("10.0.0.2"))
+}
+
+func TestExporterRetention(t *testing.T) {
+	_, err := newExporterInventory("", &public.ExportersSpec{SilentAfter: "10m", Retention: "5m"})
+	assert.Error(t, err)
+	_, err = newExporterInventory("", &public.ExportersSpec{Retention: "never"})
+	assert.Error(t, err)
+
+	// default is multiple of silent_after
+	inventory, err := newExporterInventory("", &public.ExportersSpec{SilentAfter: "1m"})
+	assert.NoError(t, err)
+	assert.Equal(t, 12*time.Minute, inventory.retention)
+
+	inventory, err = newExporterInventory("", &public.ExportersSpec{SilentAfter: "1m", Retention: "10m"})
+	assert.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	inventory.now = func() time.Time {
+		return now
+	}
+	inventory.packet("10.0.0.1", "netflow_v5", 100)
+	inventory.packet("10.0.0.2", "netflow_v5", 100)
+	now = now.Add(6 * time.Minute)
+	inventory.packet("10.0.0.2", "netflow_v5", 100)
+	now = now.Add(5 * time.Minute)
+
+	// exporter is dropped from inventory and its series disappears
+	list := inventory.status()
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, "10.0.0.2", list[0].Address)
+	assert.True(t, list[0].Silent)
+	assert.Equal(t, uint32(0), inventory.samplingRate("10.0.0.1"))
+	assert.NoError(t, testutil.CollectAndCompare(inventory, strings.NewReader(`
+# HELP receiver_exporter_silent Whether exporter did not send any packet for longer than configured threshold (1) or not (0)
+# TYPE receiver_exporter_silent gauge
+receiver_exporter_silent{sampler="10.0.0.2"} 1
+`)))
+
+	// exporter that comes back starts over
+	inventory.packet("10.0.0.1", "netflow_v5", 100)
+	list = inventory.status()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, now, list[0].FirstSeen)
+	assert.Equal(t, uint64(1), list[0].Packets)
+
+	// stale exporters are removed also when packets arrive, without anyone asking for status
+	now = now.Add(11 * time.Minute)
+	inventory.packet("10.0.0.3", "netflow_v5", 100)
+	inventory.mu.Lock()
+	assert.Equal(t, 1, len(inventory.exporters))
+	inventory.mu.Unlock()
+}
@@ -0,0 +1,89 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+// Attributes are exchanged with external enrichers (exec, wasm) as JSON objects.
+
+// encodeFlowAttr converts attribute to JSON-friendly value, addresses are represented as strings
+func encodeFlowAttr(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok && (len(b) == net.IPv4len || len(b) == net.IPv6len) {
+		return net.IP(b).String()
+	}
+	return v
+}
+
+// decodeFlowAttr converts value returned by process to attribute value. Non-negative integers that fit
+// into uint32 are converted to uint32, as that is what metric label converters expect.
+func decodeFlowAttr(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case string, bool:
+		return x, true
+	case float64:
+		if x >= 0 && x <= math.MaxUint32 && x == math.Trunc(x) {
+			return uint32(x), true
+		}
+		return x, true
+	default:
+		return nil, false
+	}
+}
+
+// encodeFlowAttrs returns all attributes of flow in JSON-friendly form
+func encodeFlowAttrs(flow *public.Flow) map[string]interface{} {
+	attrs := flow.Attrs()
+	for k, v := range attrs {
+		attrs[k] = encodeFlowAttr(v)
+	}
+	return attrs
+}
+
+// mergeFlowAttrs adds decoded attributes to flow. Existing attributes can only be replaced by value of same type.
+// Attributes which were rejected because of their type are reported in error, other ones are added anyway.
+func mergeFlowAttrs(flow *public.Flow, attrs map[string]interface{}) error {
+	var rejected []string
+	for k, v := range attrs {
+		val, ok := decodeFlowAttr(v)
+		if !ok {
+			rejected = append(rejected, k)
+			continue
+		}
+		if old := flow.Raw(k); old != nil && reflect.TypeOf(old) != reflect.TypeOf(val) {
+			rejected = append(rejected, k)
+			continue
+		}
+		flow.AddAttr(k, val)
+	}
+	return rejectedAttrsError(rejected)
+}
+
+// rejectedAttrsError returns error naming attributes that could not be added to flow, or nil if there are none
+func rejectedAttrsError(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+	return fmt.Errorf("attributes rejected because of their type: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,224 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// MRT format as defined in RFC 6396, with ADD-PATH extension from RFC 8050.
+const (
+	mrtTypeTableDumpV2 = 13
+
+	mrtSubtypeRibIPv4Unicast        = 2
+	mrtSubtypeRibIPv6Unicast        = 4
+	mrtSubtypeRibIPv4UnicastAddPath = 8
+	mrtSubtypeRibIPv6UnicastAddPath = 10
+
+	bgpAttrFlagExtendedLength = 0x10
+	bgpAttrTypeAsPath         = 2
+
+	bgpAsPathSegmentSet      = 1
+	bgpAsPathSegmentSequence = 2
+)
+
+// maximum length of MRT record, RIB entry of prefix seen by thousands of peers fits easily
+const mrtMaxRecordLength = 16 * 1024 * 1024
+
+var errMrtTruncated = errors.New("truncated MRT record")
+
+// mrtRibEntry is a single route to prefix, as seen by one of peers
+type mrtRibEntry struct {
+	peerIndex uint16
+	// AS path with 4-byte AS numbers; AS_SET segments are flattened
+	asPath []uint32
+	// number of hops used for path selection, AS_SET counts as one
+	pathLen int
+}
+
+// originAs is the last AS in path, that is the one which originated the prefix
+func (e *mrtRibEntry) originAs() uint32 {
+	if len(e.asPath) == 0 {
+		return 0
+	}
+	return e.asPath[len(e.asPath)-1]
+}
+
+// peerAs is the first AS in path, that is AS of peer from which route collector learned the route
+func (e *mrtRibEntry) peerAs() uint32 {
+	if len(e.asPath) == 0 {
+		return 0
+	}
+	return e.asPath[0]
+}
+
+// readMrtRib reads TABLE_DUMP_V2 RIB records from stream and calls fn for each prefix with all its entries.
+// Records of other types and subtypes are skipped.
+func readMrtRib(r io.Reader, fn func(prefix *net.IPNet, entries []mrtRibEntry)) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	hdr := make([]byte, 12)
+	var body []byte
+	for {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return errMrtTruncated
+		}
+		typ := binary.BigEndian.Uint16(hdr[4:6])
+		subtype := binary.BigEndian.Uint16(hdr[6:8])
+		length := binary.BigEndian.Uint32(hdr[8:12])
+		if length > mrtMaxRecordLength {
+			return fmt.Errorf("MRT record too long: %d bytes", length)
+		}
+		if cap(body) < int(length) {
+			body = make([]byte, length)
+		}
+		body = body[:length]
+		if _, err := io.ReadFull(br, body); err != nil {
+			return errMrtTruncated
+		}
+		if typ != mrtTypeTableDumpV2 {
+			continue
+		}
+		var (
+			family  int
+			addPath bool
+		)
+		switch subtype {
+		case mrtSubtypeRibIPv4Unicast:
+			family = net.IPv4len
+		case mrtSubtypeRibIPv6Unicast:
+			family = net.IPv6len
+		case mrtSubtypeRibIPv4UnicastAddPath:
+			family, addPath = net.IPv4len, true
+		case mrtSubtypeRibIPv6UnicastAddPath:
+			family, addPath = net.IPv6len, true
+		default:
+			continue
+		}
+		prefix, entries, err := parseMrtRib(body, family, addPath)
+		if err != nil {
+			return err
+		}
+		fn(prefix, entries)
+	}
+}
+
+func parseMrtRib(b []byte, family int, addPath bool) (*net.IPNet, []mrtRibEntry, error) {
+	// sequence number (4), prefix length (1)
+	if len(b) < 5 {
+		return nil, nil, errMrtTruncated
+	}
+	ones := int(b[4])
+	if ones > family*8 {
+		return nil, nil, fmt.Errorf("invalid prefix length in MRT record: %d", ones)
+	}
+	n := (ones + 7) / 8
+	b = b[5:]
+	if len(b) < n+2 {
+		return nil, nil, errMrtTruncated
+	}
+	ip := make(net.IP, family)
+	copy(ip, b[:n])
+	prefix := &net.IPNet{IP: ip, Mask: net.CIDRMask(ones, family*8)}
+	count := int(binary.BigEndian.Uint16(b[n:]))
+	b = b[n+2:]
+	entries := make([]mrtRibEntry, 0, count)
+	for i := 0; i < count; i++ {
+		// peer index (2), originated time (4), [path identifier (4)], attribute length (2)
+		hl := 8
+		if addPath {
+			hl += 4
+		}
+		if len(b) < hl {
+			return nil, nil, errMrtTruncated
+		}
+		e := mrtRibEntry{peerIndex: binary.BigEndian.Uint16(b)}
+		attrLen := int(binary.BigEndian.Uint16(b[hl-2:]))
+		b = b[hl:]
+		if len(b) < attrLen {
+			return nil, nil, errMrtTruncated
+		}
+		if err := parseBgpAttributes(b[:attrLen], &e); err != nil {
+			return nil, nil, err
+		}
+		b = b[attrLen:]
+		entries = append(entries, e)
+	}
+	return prefix, entries, nil
+}
+
+func parseBgpAttributes(b []byte, e *mrtRibEntry) error {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return errMrtTruncated
+		}
+		flags, typ := b[0], b[1]
+		var l, hl int
+		if flags&bgpAttrFlagExtendedLength != 0 {
+			if len(b) < 4 {
+				return errMrtTruncated
+			}
+			l, hl = int(binary.BigEndian.Uint16(b[2:])), 4
+		} else {
+			l, hl = int(b[2]), 3
+		}
+		if len(b) < hl+l {
+			return errMrtTruncated
+		}
+		if typ == bgpAttrTypeAsPath {
+			if err := parseAsPath(b[hl:hl+l], e); err != nil {
+				return err
+			}
+		}
+		b = b[hl+l:]
+	}
+	return nil
+}
+
+// parseAsPath parses AS_PATH attribute, which always uses 4-byte AS numbers in TABLE_DUMP_V2.
+// Confederation segments are ignored as they are not visible outside of confederation.
+func parseAsPath(b []byte, e *mrtRibEntry) error {
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return errMrtTruncated
+		}
+		segType, count := b[0], int(b[1])
+		b = b[2:]
+		if len(b) < count*4 {
+			return errMrtTruncated
+		}
+		switch segType {
+		case bgpAsPathSegmentSequence:
+			for i := 0; i < count; i++ {
+				e.asPath = append(e.asPath, binary.BigEndian.Uint32(b[i*4:]))
+			}
+			e.pathLen += count
+		case bgpAsPathSegmentSet:
+			for i := 0; i < count; i++ {
+				e.asPath = append(e.asPath, binary.BigEndian.Uint32(b[i*4:]))
+			}
+			e.pathLen++
+		}
+		b = b[count*4:]
+	}
+	return nil
+}
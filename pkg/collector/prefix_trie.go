@@ -0,0 +1,99 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"net"
+)
+
+type trieNode[T any] struct {
+	children [2]*trieNode[T]
+	value    T
+	set      bool
+}
+
+// prefixTrie is binary trie of IP prefixes supporting longest-prefix match.
+// IPv4 and IPv6 prefixes are kept in separate trees.
+type prefixTrie[T any] struct {
+	v4   *trieNode[T]
+	v6   *trieNode[T]
+	size int
+}
+
+func newPrefixTrie[T any]() *prefixTrie[T] {
+	return &prefixTrie[T]{v4: &trieNode[T]{}, v6: &trieNode[T]{}}
+}
+
+func (t *prefixTrie[T]) root(ip net.IP) (*trieNode[T], net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return t.v4, ip4
+	}
+	return t.v6, ip.To16()
+}
+
+func bitAt(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
+
+// insert adds prefix to trie, value of existing prefix is replaced
+func (t *prefixTrie[T]) insert(prefix *net.IPNet, value T) {
+	ones, bits := prefix.Mask.Size()
+	// tree is chosen by length of mask, IPv4-mapped IPv6 prefix is stored as IPv4 one,
+	// since addresses are looked up in IPv4 tree
+	n, ip := t.v6, prefix.IP.To16()
+	if ip4 := prefix.IP.To4(); ip4 != nil && (bits == 32 || ones >= 96) {
+		n, ip = t.v4, ip4
+		if bits == 128 {
+			ones -= 96
+		}
+	}
+	if ip == nil || bits == 0 {
+		return
+	}
+	for i := 0; i < ones; i++ {
+		b := bitAt(ip, i)
+		if n.children[b] == nil {
+			n.children[b] = &trieNode[T]{}
+		}
+		n = n.children[b]
+	}
+	if !n.set {
+		t.size++
+	}
+	n.value = value
+	n.set = true
+}
+
+// lookup finds value of the most specific prefix containing given address, along with length of that prefix
+func (t *prefixTrie[T]) lookup(addr net.IP) (value T, ones int, found bool) {
+	n, ip := t.root(addr)
+	if ip == nil {
+		return value, 0, false
+	}
+	for i := 0; n != nil; i++ {
+		if n.set {
+			value, ones, found = n.value, i, true
+		}
+		if i == len(ip)*8 {
+			break
+		}
+		n = n.children[bitAt(ip, i)]
+	}
+	return value, ones, found
+}
+
+func (t *prefixTrie[T]) len() int {
+	return t.size
+}
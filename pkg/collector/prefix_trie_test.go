@@ -0,0 +1,62 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixTrie(t *testing.T) {
+	tr := newPrefixTrie[string]()
+	for _, p := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32", "2001:db8::/32"} {
+		_, n, err := net.ParseCIDR(p)
+		assert.NoError(t, err)
+		tr.insert(n, p)
+	}
+	assert.Equal(t, 5, tr.len())
+
+	for ip, expected := range map[string]string{
+		"10.1.2.3":    "10.1.2.3/32",
+		"10.1.2.4":    "10.1.0.0/16",
+		"10.2.0.1":    "10.0.0.0/8",
+		"192.168.0.1": "0.0.0.0/0",
+		"2001:db8::1": "2001:db8::/32",
+	} {
+		v, _, ok := tr.lookup(net.ParseIP(ip))
+		assert.True(t, ok, ip)
+		assert.Equal(t, expected, v, ip)
+	}
+	_, ones, _ := tr.lookup(net.ParseIP("10.1.9.9"))
+	assert.Equal(t, 16, ones)
+
+	_, _, ok := tr.lookup(net.ParseIP("2001:db9::1"))
+	assert.False(t, ok)
+
+	// IPv4-mapped IPv6 prefix is same as IPv4 one
+	_, n, err := net.ParseCIDR("::ffff:172.16.0.0/108")
+	assert.NoError(t, err)
+	tr.insert(n, "mapped")
+	v, ones, ok := tr.lookup(net.ParseIP("172.16.1.1"))
+	assert.True(t, ok)
+	assert.Equal(t, "mapped", v)
+	assert.Equal(t, 12, ones)
+	v, _, _ = tr.lookup(net.ParseIP("::ffff:172.16.1.1"))
+	assert.Equal(t, "mapped", v)
+	v, _, _ = tr.lookup(net.ParseIP("172.32.0.1"))
+	assert.Equal(t, "0.0.0.0/0", v)
+}
@@ -24,15 +24,25 @@ import (
 	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
 )
 
+// messageConsumer receives all flows decoded from single packet at once
 type messageConsumer interface {
-	Consume(msg *flowpb.FlowMessage)
+	Publish(messages []*flowpb.FlowMessage)
 }
 
 type producerMetricAdapter struct {
 	consumer messageConsumer
+	receiver *receiverMetrics
 }
 
-func (p *producerMetricAdapter) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+func (p *producerMetricAdapter) Produce(msg any, args *producer.ProduceArgs) (msgs []producer.ProducerMessage, err error) {
+	defer func() {
+		if err != nil {
+			err = &producerError{err: err}
+		}
+	}()
+	if p.receiver != nil {
+		p.receiver.observePacket(args.SamplerAddress.Unmap().String(), msg)
+	}
 	tr := uint64(args.TimeReceived.UnixNano())
 	sa, _ := args.SamplerAddress.Unmap().MarshalBinary()
 	if rpt, ok := msg.(*netflowlegacy.PacketNetFlowV5); ok {
@@ -54,9 +64,11 @@ func (p *producerMetricAdapter) Produce(msg any, args *producer.ProduceArgs) ([]
 }
 
 func (p *producerMetricAdapter) Commit(messages []producer.ProducerMessage) {
+	msgs := make([]*flowpb.FlowMessage, 0, len(messages))
 	for _, msg := range messages {
-		p.consumer.Consume(&(msg.(*protoproducer.ProtoProducerMessage)).FlowMessage)
+		msgs = append(msgs, &(msg.(*protoproducer.ProtoProducerMessage)).FlowMessage)
 	}
+	p.consumer.Publish(msgs)
 }
 
 func (p *producerMetricAdapter) Close() {}
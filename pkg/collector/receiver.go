@@ -0,0 +1,183 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// receiverMetrics tracks export packets from the moment they are received until they are decoded into flows.
+type receiverMetrics struct {
+	logger           *slog.Logger
+	packets          *prometheus.CounterVec
+	bytes            *prometheus.CounterVec
+	decodeErrors     *prometheus.CounterVec
+	templatesMissing *prometheus.CounterVec
+	queueDrops       *prometheus.CounterVec
+	sequences        *sequenceTracker
+	inventory        *exporterInventory
+}
+
+func newReceiverMetrics(prefix string, logger *slog.Logger, inventory *exporterInventory) *receiverMetrics {
+	return &receiverMetrics{
+		logger:    logger,
+		inventory: inventory,
+		packets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "packets",
+			Help:      "The total number of received export packets.",
+		}, []string{"sampler", "protocol"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "bytes",
+			Help:      "The total number of bytes in received export packets.",
+		}, []string{"sampler", "protocol"}),
+		decodeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "decode_errors",
+			Help:      "The total number of export packets that could not be decoded, by protocol and error type.",
+		}, []string{"protocol", "error"}),
+		templatesMissing: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "templates_missing",
+			Help:      "The total number of export packets with data for which template was not received yet.",
+		}, []string{"sampler"}),
+		queueDrops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "queue_drops",
+			Help:      "The total number of export packets dropped because receive queue was full.",
+		}, []string{"sampler"}),
+		sequences: newSequenceTracker(prefix, inventory.retention),
+	}
+}
+
+func (r *receiverMetrics) Describe(ch chan<- *prometheus.Desc) {
+	r.packets.Describe(ch)
+	r.bytes.Describe(ch)
+	r.decodeErrors.Describe(ch)
+	r.templatesMissing.Describe(ch)
+	r.queueDrops.Describe(ch)
+	r.sequences.Describe(ch)
+	r.inventory.Describe(ch)
+}
+
+func (r *receiverMetrics) Collect(ch chan<- prometheus.Metric) {
+	r.packets.Collect(ch)
+	r.bytes.Collect(ch)
+	r.decodeErrors.Collect(ch)
+	r.templatesMissing.Collect(ch)
+	r.queueDrops.Collect(ch)
+	r.sequences.Collect(ch)
+	r.inventory.Collect(ch)
+}
+
+// exportProtocol detects protocol of export packet from its header, same way as flow pipe does
+func exportProtocol(payload []byte) string {
+	if len(payload) < 4 {
+		return "unknown"
+	}
+	if binary.BigEndian.Uint32(payload) == 5 {
+		return "sflow"
+	}
+	switch binary.BigEndian.Uint16(payload) {
+	case 5:
+		return "netflow_v5"
+	case 9:
+		return "netflow_v9"
+	case 10:
+		return "ipfix"
+	}
+	return "unknown"
+}
+
+// decodeErrorType classifies error returned by flow pipe
+func decodeErrorType(protocol string, err error) string {
+	switch {
+	case errors.Is(err, netflow.ErrorTemplateNotFound):
+		return "template_not_found"
+	case protocol == "unknown":
+		return "unsupported"
+	default:
+		return "malformed"
+	}
+}
+
+// producerError marks error returned by producer, that is after packet was decoded and observed
+type producerError struct {
+	err error
+}
+
+func (e *producerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *producerError) Unwrap() error {
+	return e.err
+}
+
+// Dropped is called by UDP receiver when packet is dropped due to full queue
+func (r *receiverMetrics) Dropped(msg utils.Message) {
+	sampler := msg.Src.Addr().Unmap().String()
+	r.queueDrops.WithLabelValues(sampler).Inc()
+	r.inventory.queueDrop(sampler)
+}
+
+// observePacket is called by producer with every decoded export packet
+func (r *receiverMetrics) observePacket(sampler string, msg any) {
+	r.sequences.observePacket(sampler, msg)
+	r.inventory.observePacket(sampler, msg)
+}
+
+// wrap returns decoder function that records metrics about every packet passed to decoder
+func (r *receiverMetrics) wrap(decode utils.DecoderFunc) utils.DecoderFunc {
+	return func(msg interface{}) error {
+		pkt, ok := msg.(*utils.Message)
+		if !ok {
+			return decode(msg)
+		}
+		sampler := pkt.Src.Addr().Unmap().String()
+		protocol := exportProtocol(pkt.Payload)
+		r.packets.WithLabelValues(sampler, protocol).Inc()
+		r.bytes.WithLabelValues(sampler, protocol).Add(float64(len(pkt.Payload)))
+		r.inventory.packet(sampler, protocol, len(pkt.Payload))
+		err := decode(msg)
+		if err != nil {
+			typ := decodeErrorType(protocol, err)
+			r.decodeErrors.WithLabelValues(protocol, typ).Inc()
+			r.inventory.decodeError(sampler, typ)
+			if typ == "template_not_found" {
+				r.templatesMissing.WithLabelValues(sampler).Inc()
+			}
+			// packet that was not decoded still takes its place in sequence numbering
+			var pe *producerError
+			if !errors.As(err, &pe) {
+				r.sequences.observeHeader(sampler, pkt.Payload)
+			}
+			r.logger.Debug("unable to decode packet", "sampler", sampler, "protocol", protocol, "err", err)
+		}
+		return err
+	}
+}
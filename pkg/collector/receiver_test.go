@@ -0,0 +1,113 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingConsumer struct {
+	count int
+}
+
+func (c *countingConsumer) Publish(msgs []*flowpb.FlowMessage) {
+	c.count += len(msgs)
+}
+
+// netflowV5Packet builds NetFlow v5 packet with given flow sequence and number of (empty) records
+func netflowV5Packet(seq uint32, count int) []byte {
+	b := make([]byte, 24+count*48)
+	binary.BigEndian.PutUint16(b[0:], 5)
+	binary.BigEndian.PutUint16(b[2:], uint16(count))
+	binary.BigEndian.PutUint32(b[16:], seq)
+	return b
+}
+
+// netflowV9Packet builds NetFlow v9 packet with single data flowset that refers to unknown template
+func netflowV9Packet(seq uint32) []byte {
+	b := make([]byte, 28)
+	binary.BigEndian.PutUint16(b[0:], 9)
+	binary.BigEndian.PutUint16(b[2:], 1)
+	binary.BigEndian.PutUint32(b[12:], seq)
+	binary.BigEndian.PutUint16(b[20:], 256)
+	binary.BigEndian.PutUint16(b[22:], 8)
+	return b
+}
+
+func exportMessage(src string, payload []byte) *utils.Message {
+	return &utils.Message{
+		Src:      netip.MustParseAddrPort(src),
+		Dst:      netip.MustParseAddrPort("127.0.0.1:2055"),
+		Payload:  payload,
+		Received: time.Now(),
+	}
+}
+
+func TestExportProtocol(t *testing.T) {
+	assert.Equal(t, "netflow_v5", exportProtocol(netflowV5Packet(0, 1)))
+	assert.Equal(t, "netflow_v9", exportProtocol(netflowV9Packet(0)))
+	assert.Equal(t, "ipfix", exportProtocol([]byte{0, 10, 0, 16}))
+	assert.Equal(t, "sflow", exportProtocol([]byte{0, 0, 0, 5}))
+	assert.Equal(t, "unknown", exportProtocol([]byte{0, 5}))
+	assert.Equal(t, "unknown", exportProtocol([]byte{1, 2, 3, 4}))
+}
+
+func TestReceiverMetrics(t *testing.T) {
+	inventory, err := newExporterInventory("", nil)
+	assert.NoError(t, err)
+	r := newReceiverMetrics("", baseLogger, inventory)
+	consumer := &countingConsumer{}
+	pipe := utils.NewFlowPipe(&utils.PipeConfig{
+		Producer: &producerMetricAdapter{consumer: consumer, receiver: r},
+	})
+	decode := r.wrap(pipe.DecodeFlow)
+
+	assert.NoError(t, decode(exportMessage("10.0.0.1:5000", netflowV5Packet(100, 2))))
+	assert.NoError(t, decode(exportMessage("10.0.0.1:5000", netflowV5Packet(102, 1))))
+	// 3 flows are missing
+	assert.NoError(t, decode(exportMessage("10.0.0.1:5000", netflowV5Packet(106, 1))))
+	assert.Equal(t, 4, consumer.count)
+	assert.Equal(t, 3.0, testutil.ToFloat64(r.packets.WithLabelValues("10.0.0.1", "netflow_v5")))
+	assert.Equal(t, float64(3*24+4*48), testutil.ToFloat64(r.bytes.WithLabelValues("10.0.0.1", "netflow_v5")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.sequences.gaps.WithLabelValues("10.0.0.1", "netflow_v5", "0")))
+
+	assert.Error(t, decode(exportMessage("10.0.0.2:5000", netflowV9Packet(1))))
+	assert.Error(t, decode(exportMessage("10.0.0.2:5000", []byte{1, 2, 3, 4, 5, 6})))
+	assert.Error(t, decode(exportMessage("10.0.0.2:5000", netflowV5Packet(0, 1)[:10])))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.decodeErrors.WithLabelValues("netflow_v9", "template_not_found")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.templatesMissing.WithLabelValues("10.0.0.2")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.decodeErrors.WithLabelValues("unknown", "unsupported")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.decodeErrors.WithLabelValues("netflow_v5", "malformed")))
+
+	// packet with data of unknown template takes its place in sequence numbering, so it does not cause gap
+	assert.NoError(t, decode(exportMessage("10.0.0.4:2055", netflowV9TemplatePacket(0))))
+	unknown := netflowV9Packet(1)
+	binary.BigEndian.PutUint16(unknown[20:], 300)
+	assert.Error(t, decode(exportMessage("10.0.0.4:2055", unknown)))
+	assert.NoError(t, decode(exportMessage("10.0.0.4:2055", netflowV9TemplatePacket(2))))
+	assert.Equal(t, 2.0, testutil.ToFloat64(r.decodeErrors.WithLabelValues("netflow_v9", "template_not_found")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.sequences.gaps.WithLabelValues("10.0.0.4", "netflow_v9", "0")))
+
+	r.Dropped(*exportMessage("[::ffff:10.0.0.3]:5000", netflowV5Packet(0, 1)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.queueDrops.WithLabelValues("10.0.0.3")))
+}
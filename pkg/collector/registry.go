@@ -0,0 +1,114 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+// registryEntry holds factory of either kind of enricher
+type registryEntry struct {
+	v1 public.EnricherFactory
+	v2 public.EnricherV2Factory
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]registryEntry{}
+)
+
+// RegisterEnricher makes enricher available under given name, so that it can be referenced from pipeline.
+// Factory is called to create new instance every time enricher is used.
+// Error is returned if name is empty or contains '/', factory is nil or name is already registered.
+func RegisterEnricher(name string, factory public.EnricherFactory) error {
+	if factory == nil {
+		return fmt.Errorf("factory of enricher %s must not be nil", name)
+	}
+	return register(name, registryEntry{v1: factory})
+}
+
+// RegisterEnricherV2 is like RegisterEnricher, but for enrichers implementing public.EnricherV2.
+func RegisterEnricherV2(name string, factory public.EnricherV2Factory) error {
+	if factory == nil {
+		return fmt.Errorf("factory of enricher %s must not be nil", name)
+	}
+	return register(name, registryEntry{v2: factory})
+}
+
+func register(name string, entry registryEntry) error {
+	if len(name) == 0 {
+		return fmt.Errorf("enricher name must not be empty")
+	}
+	// slash separates enricher name from instance name in pipeline
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("enricher name %s must not contain '/'", name)
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("enricher %s is already registered", name)
+	}
+	registry[name] = entry
+	return nil
+}
+
+// MustRegisterEnricher is like RegisterEnricher, but panics on error.
+func MustRegisterEnricher(name string, factory public.EnricherFactory) {
+	if err := RegisterEnricher(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// MustRegisterEnricherV2 is like RegisterEnricherV2, but panics on error.
+func MustRegisterEnricherV2(name string, factory public.EnricherV2Factory) {
+	if err := RegisterEnricherV2(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// EnricherNames returns sorted names of all registered enrichers.
+func EnricherNames() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// newEnricher creates new instance of enricher registered under given name, legacy enrichers are adapted
+// to public.EnricherV2. Second value is the underlying instance, which may implement other interfaces.
+func newEnricher(name string) (public.EnricherV2, interface{}) {
+	registryLock.RLock()
+	entry, ok := registry[name]
+	registryLock.RUnlock()
+	switch {
+	case !ok:
+		return nil, nil
+	case entry.v2 != nil:
+		e := entry.v2()
+		return e, e
+	default:
+		e := entry.v1()
+		return public.AdaptEnricher(e), e
+	}
+}
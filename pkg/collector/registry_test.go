@@ -0,0 +1,256 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+type constEnricher struct {
+	value string
+}
+
+func (c *constEnricher) Close() error { return nil }
+func (c *constEnricher) Start() error { return nil }
+
+func (c *constEnricher) Configure(cfg map[string]interface{}) {
+	c.value = cfgString(cfg, "value", "none")
+}
+
+func (c *constEnricher) Enrich(flow *public.Flow) {
+	flow.AddAttr("const", c.value)
+}
+
+// checkedEnricher implements public.EnricherV2, it fails flows without source_ip
+type checkedEnricher struct {
+	ctx    context.Context
+	closed bool
+}
+
+func (c *checkedEnricher) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *checkedEnricher) Configure(cfg map[string]interface{}) error {
+	if _, ok := cfg["unknown"]; ok {
+		return errors.New("unknown option")
+	}
+	return nil
+}
+
+func (c *checkedEnricher) Start(ctx context.Context) error {
+	c.ctx = ctx
+	return nil
+}
+
+func (c *checkedEnricher) Enrich(flow *public.Flow) error {
+	if flow.Raw("source_ip") == nil {
+		return errors.New("missing source_ip")
+	}
+	flow.AddAttr("checked", true)
+	return nil
+}
+
+func TestRegisterEnricher(t *testing.T) {
+	factory := func() public.Enricher { return &constEnricher{} }
+	assert.NoError(t, RegisterEnricher("test_const", factory))
+	t.Cleanup(func() {
+		unregisterEnricher("test_const")
+	})
+	assert.Error(t, RegisterEnricher("test_const", factory))
+	assert.Error(t, RegisterEnricher("reverse_dns", factory))
+	assert.Error(t, RegisterEnricher("", factory))
+	assert.ErrorContains(t, RegisterEnricher("test/const", factory), "must not contain '/'")
+	assert.Error(t, RegisterEnricher("test_nil", nil))
+	assert.Panics(t, func() {
+		MustRegisterEnricher("test_const", factory)
+	})
+
+	names := EnricherNames()
+	assert.Contains(t, names, "test_const")
+	assert.Contains(t, names, "host_alias")
+	assert.NotContains(t, names, "test_nil")
+	assert.IsNonDecreasing(t, names)
+
+	// every use gets its own instance
+	assert.NotSame(t, getEnricher("test_const"), getEnricher("test_const"))
+	assert.Nil(t, getEnricher("test_missing"))
+
+	c := New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"test_const"},
+		},
+		Extensions: map[string]map[string]interface{}{
+			"test_const": {"value": "hello"},
+		},
+	}, baseLogger).(*col)
+	assert.NoError(t, c.startEnrichers())
+	f := &public.Flow{}
+	c.enrichers[0].Enrich(f)
+	assert.Equal(t, "hello", *f.AsString("const"))
+
+	c = New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"test_missing"},
+		},
+	}, baseLogger).(*col)
+	assert.ErrorContains(t, c.startEnrichers(), "unknown enricher : test_missing")
+}
+
+func TestEnricherV2(t *testing.T) {
+	var instance *checkedEnricher
+	factory := func() public.EnricherV2 {
+		instance = &checkedEnricher{}
+		return instance
+	}
+	assert.NoError(t, RegisterEnricherV2("test_checked", factory))
+	t.Cleanup(func() {
+		unregisterEnricher("test_checked")
+	})
+	assert.Error(t, RegisterEnricherV2("test_checked", factory))
+	assert.Error(t, RegisterEnricher("test_checked", func() public.Enricher { return &constEnricher{} }))
+	assert.Error(t, RegisterEnricherV2("test_nil", nil))
+	assert.Error(t, RegisterEnricherV2("test/checked", factory))
+	assert.Contains(t, EnricherNames(), "test_checked")
+	assert.Nil(t, getEnricher("test_checked"))
+
+	c := New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"test_checked"},
+		},
+		Extensions: map[string]map[string]interface{}{
+			"test_checked": {"unknown": 1},
+		},
+	}, baseLogger).(*col)
+	assert.ErrorContains(t, c.startEnrichers(), "invalid configuration of enricher test_checked in extensions.test_checked: unknown option")
+
+	// enrichers started before failure are closed
+	c = New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"test_checked", "test_missing"},
+		},
+	}, baseLogger).(*col)
+	assert.ErrorContains(t, c.start(), "unknown enricher : test_missing")
+	assert.True(t, instance.closed)
+	assert.Empty(t, c.enrichers)
+
+	c = New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"test_checked"},
+		},
+	}, baseLogger).(*col)
+	assert.NoError(t, c.start())
+	ok, failed := &public.Flow{}, &public.Flow{}
+	ok.AddAttr("source_ip", []byte{10, 0, 0, 1})
+	c.processFlow(ok)
+	c.processFlow(failed)
+	assert.Equal(t, true, ok.Raw("checked"))
+	assert.Nil(t, failed.Raw("checked"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.enricherErrors.WithLabelValues("test_checked")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.enricherHits.WithLabelValues("test_checked")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.enricherMisses.WithLabelValues("test_checked")))
+
+	assert.NoError(t, c.Close())
+	assert.True(t, instance.closed)
+	assert.Error(t, instance.ctx.Err())
+}
+
+func TestLegacyEnricherConfigError(t *testing.T) {
+	c := New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"interface_mapper/core"},
+		},
+		Extensions: map[string]map[string]interface{}{
+			"interface_mapper/core": {"1": 10},
+		},
+	}, baseLogger).(*col)
+	assert.ErrorContains(t, c.startEnrichers(), "extensions.interface_mapper/core: value of 1 must be")
+
+	c = New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"reverse_dns"},
+		},
+		Extensions: map[string]map[string]interface{}{
+			"reverse_dns": {"max_concurrency": 0},
+		},
+	}, baseLogger).(*col)
+	assert.ErrorContains(t, c.startEnrichers(), "extensions.reverse_dns: max_concurrency (if specified) must be a positive integer")
+}
+
+func TestNamedEnricherInstances(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "list.txt")
+	assert.NoError(t, os.WriteFile(list, []byte("8.8.8.8\n"), 0o600))
+	c := New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"host_alias/lan", "host_alias/dmz", "threat_intel", "threat_intel/extra"},
+		},
+		Extensions: map[string]map[string]interface{}{
+			"host_alias/lan": {"alias_map": map[string]interface{}{"192.168.0.1": "gateway"}},
+			"host_alias/dmz": {"alias_map": map[string]interface{}{"192.168.0.1": "firewall"}},
+			"threat_intel": {"lists": []interface{}{
+				map[string]interface{}{"name": "first", "file": list},
+			}},
+			"threat_intel/extra": {"lists": []interface{}{
+				map[string]interface{}{"name": "second", "file": list},
+			}},
+		},
+	}, baseLogger).(*col)
+	assert.NoError(t, c.startEnrichers())
+	defer func() {
+		for _, e := range c.enrichers {
+			_ = e.Close()
+		}
+	}()
+	assert.Equal(t, 4, len(c.enrichers))
+
+	lan, dmz := &public.Flow{}, &public.Flow{}
+	lan.AddAttr("source_ip", []byte{192, 168, 0, 1})
+	dmz.AddAttr("source_ip", []byte{192, 168, 0, 1})
+	c.enrichers[0].Enrich(lan)
+	c.enrichers[1].Enrich(dmz)
+	assert.Equal(t, "gateway", *lan.AsString("source_host_alias"))
+	assert.Equal(t, "firewall", *dmz.AsString("source_host_alias"))
+
+	// metrics of both instances can be registered together
+	reg := prometheus.NewPedanticRegistry()
+	for _, em := range c.enricherMetrics {
+		assert.NoError(t, reg.Register(em))
+	}
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP threat_intel_list_entries The number of addresses and networks currently loaded from blocklist.
+# TYPE threat_intel_list_entries gauge
+threat_intel_list_entries{enricher="threat_intel",list="first"} 1
+threat_intel_list_entries{enricher="threat_intel/extra",list="second"} 1
+`), "threat_intel_list_entries"))
+
+	c = New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"host_alias", "host_alias"},
+		},
+	}, baseLogger).(*col)
+	assert.ErrorContains(t, c.startEnrichers(), "listed more than once")
+}
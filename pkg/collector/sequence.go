@@ -0,0 +1,430 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/decoders/netflowlegacy"
+	"github.com/netsampler/goflow2/v2/decoders/sflow"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// number of packets after which gap that was not filled by reordered packets is considered lost
+	sequenceReorderWindow = 16
+	// maximum number of gaps per stream waiting for reordered packets
+	sequenceMaxPendingGaps = 64
+	// how many packets (worth of sequence numbers) can sequence go back before it is considered reset,
+	// used only with protocols that don't carry uptime of exporter
+	sequenceResetPackets = 1000
+	// how much can uptime of exporter go back (in milliseconds) before it is considered restart,
+	// reordered packets carry slightly lower uptime
+	sequenceUptimeTolerance = 10000
+	// how often are stale streams looked for when packets arrive
+	sequenceEvictInterval = time.Minute
+)
+
+// sequenceKey identifies stream of export packets that share sequence numbering
+type sequenceKey struct {
+	sampler  string
+	protocol string
+	// engine type and id (NetFlow v5), source id (NetFlow v9), observation domain (IPFIX) or sub-agent (sFlow)
+	domain uint32
+}
+
+// sequenceObservation is what export packet tells about its sequence numbering
+type sequenceObservation struct {
+	key sequenceKey
+	seq uint32
+	// number of units that packet accounts for in sequence numbering
+	count uint32
+	// whether units are flows (NetFlow v5, IPFIX), rather than packets (NetFlow v9, sFlow)
+	countsFlows bool
+	// number of flows in packet
+	flows uint32
+	// uptime of exporter in milliseconds, zero if protocol does not carry it
+	uptime uint32
+	// packet could not be decoded, so number of its flows is not known
+	undecoded bool
+	// number of units is not known either (IPFIX), next packet can continue from any higher sequence number
+	unknownCount bool
+}
+
+// sequenceGap is range of missing sequence numbers, [start, end)
+type sequenceGap struct {
+	start uint32
+	end   uint32
+	// number of packets observed since gap was detected
+	age int
+}
+
+type sequenceState struct {
+	next    uint32
+	uptime  uint32
+	pending []*sequenceGap
+	// totals used to convert lost flows to packets and vice versa
+	packets uint64
+	flows   uint64
+	// the largest number of units seen in single packet
+	maxCount uint32
+	// previous packet did not tell how many units it accounts for, so jump forward is not a gap
+	resync   bool
+	lastSeen time.Time
+}
+
+// sequenceTracker follows sequence numbers of export packets. Gaps wait for reordered packets for a while,
+// those that are not filled are counted as lost. Sequence that goes back is either reordered packet,
+// or restart of exporter, in which case tracking starts over.
+type sequenceTracker struct {
+	// streams without any packet for this long are removed together with their series
+	retention time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	streams   map[sequenceKey]*sequenceState
+	lastEvict time.Time
+
+	gaps        *prometheus.CounterVec
+	reorders    *prometheus.CounterVec
+	resets      *prometheus.CounterVec
+	lostFlows   *prometheus.CounterVec
+	lostPackets *prometheus.CounterVec
+}
+
+func newSequenceTracker(prefix string, retention time.Duration) *sequenceTracker {
+	labels := []string{"sampler", "protocol", "domain"}
+	return &sequenceTracker{
+		retention: retention,
+		now:       time.Now,
+		streams:   map[sequenceKey]*sequenceState{},
+		gaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "sequence_gaps",
+			Help:      "The total number of gaps in sequence numbers of export packets.",
+		}, labels),
+		reorders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "sequence_reorders",
+			Help:      "The total number of export packets that arrived after packet with higher sequence number.",
+		}, labels),
+		resets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "sequence_resets",
+			Help:      "The total number of times exporter started sequence numbering over.",
+		}, labels),
+		lostFlows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "lost_flows",
+			Help:      "The estimated number of flows in export packets that were never received.",
+		}, labels),
+		lostPackets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prefix,
+			Subsystem: "receiver",
+			Name:      "lost_packets",
+			Help:      "The estimated number of export packets that were never received.",
+		}, labels),
+	}
+}
+
+func (t *sequenceTracker) Describe(ch chan<- *prometheus.Desc) {
+	t.gaps.Describe(ch)
+	t.reorders.Describe(ch)
+	t.resets.Describe(ch)
+	t.lostFlows.Describe(ch)
+	t.lostPackets.Describe(ch)
+}
+
+func (t *sequenceTracker) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	t.evict(t.now())
+	t.mu.Unlock()
+	t.gaps.Collect(ch)
+	t.reorders.Collect(ch)
+	t.resets.Collect(ch)
+	t.lostFlows.Collect(ch)
+	t.lostPackets.Collect(ch)
+}
+
+func (k sequenceKey) labels() []string {
+	return []string{k.sampler, k.protocol, strconv.FormatUint(uint64(k.domain), 10)}
+}
+
+// evict removes streams that were not seen for longer than retention, along with their series. Lock must be held.
+func (t *sequenceTracker) evict(now time.Time) {
+	t.lastEvict = now
+	for key, s := range t.streams {
+		if now.Sub(s.lastSeen) <= t.retention {
+			continue
+		}
+		delete(t.streams, key)
+		labels := key.labels()
+		for _, vec := range []*prometheus.CounterVec{t.gaps, t.reorders, t.resets, t.lostFlows, t.lostPackets} {
+			vec.DeleteLabelValues(labels...)
+		}
+	}
+}
+
+// fill removes range of sequence numbers covered by reordered packet from pending gaps
+func (s *sequenceState) fill(seq, count uint32) {
+	var pending []*sequenceGap
+	for _, g := range s.pending {
+		// offsets relative to start of gap, so that wrap-around does not matter
+		from, to := seq-g.start, seq-g.start+count
+		size := g.end - g.start
+		if int32(from) >= int32(size) || int32(to) <= 0 {
+			pending = append(pending, g)
+			continue
+		}
+		if int32(from) > 0 {
+			pending = append(pending, &sequenceGap{start: g.start, end: seq, age: g.age})
+		}
+		if int32(to) < int32(size) {
+			pending = append(pending, &sequenceGap{start: seq + count, end: g.end, age: g.age})
+		}
+	}
+	s.pending = pending
+}
+
+// lost converts number of lost units to flows and packets, using average number of flows per packet
+func (s *sequenceState) lost(units uint32, countsFlows bool) (float64, float64) {
+	ratio := 1.0
+	if s.packets > 0 && s.flows > 0 {
+		ratio = float64(s.flows) / float64(s.packets)
+	}
+	if countsFlows {
+		return float64(units), float64(units) / ratio
+	}
+	return float64(units) * ratio, float64(units)
+}
+
+// observe records export packet
+func (t *sequenceTracker) observe(o sequenceObservation) {
+	labels := o.key.labels()
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastEvict) >= sequenceEvictInterval {
+		t.evict(now)
+	}
+	s, ok := t.streams[o.key]
+	if ok {
+		s.lastSeen = now
+	} else {
+		s = &sequenceState{
+			next:     o.seq + o.count,
+			uptime:   o.uptime,
+			maxCount: o.count,
+			resync:   o.unknownCount,
+			lastSeen: now,
+		}
+		if !o.undecoded {
+			s.packets, s.flows = 1, uint64(o.flows)
+		}
+		t.streams[o.key] = s
+		return
+	}
+	confirm := func(g *sequenceGap) {
+		flows, packets := s.lost(g.end-g.start, o.countsFlows)
+		t.lostFlows.WithLabelValues(labels...).Add(flows)
+		t.lostPackets.WithLabelValues(labels...).Add(packets)
+	}
+	s.maxCount = max(s.maxCount, o.count)
+	resync := s.resync
+	s.resync = o.unknownCount
+	d := int32(o.seq - s.next)
+	var reset bool
+	if o.uptime > 0 {
+		reset = s.uptime > o.uptime && s.uptime-o.uptime > sequenceUptimeTolerance
+	} else {
+		reset = d < 0 && -int64(d) > int64(s.maxCount)*sequenceResetPackets
+	}
+	switch {
+	case reset:
+		// exporter restarted, gaps that were still pending are lost
+		t.resets.WithLabelValues(labels...).Inc()
+		for _, g := range s.pending {
+			confirm(g)
+		}
+		s.pending = nil
+		s.next = o.seq + o.count
+		s.uptime = o.uptime
+	case d > 0 && resync:
+		// units between previous packet and this one belong to previous packet
+		s.next = o.seq + o.count
+	case d > 0:
+		t.gaps.WithLabelValues(labels...).Inc()
+		s.pending = append(s.pending, &sequenceGap{start: s.next, end: o.seq})
+		if len(s.pending) > sequenceMaxPendingGaps {
+			confirm(s.pending[0])
+			s.pending = s.pending[1:]
+		}
+		s.next = o.seq + o.count
+	case d < 0:
+		// either fills one of pending gaps, or is duplicate or too late
+		s.fill(o.seq, o.count)
+		t.reorders.WithLabelValues(labels...).Inc()
+	default:
+		s.next = o.seq + o.count
+	}
+	s.uptime = max(s.uptime, o.uptime)
+	if !o.undecoded {
+		s.packets++
+		s.flows += uint64(o.flows)
+	}
+	// gaps that were not filled within window are lost
+	pending := s.pending[:0]
+	for _, g := range s.pending {
+		if g.age++; g.age > sequenceReorderWindow {
+			confirm(g)
+		} else {
+			pending = append(pending, g)
+		}
+	}
+	s.pending = pending
+}
+
+// dataRecords counts data records in flowsets of NetFlow v9 or IPFIX packet
+func dataRecords(flowSets []interface{}) uint32 {
+	var n uint32
+	for _, fs := range flowSets {
+		switch x := fs.(type) {
+		case netflow.DataFlowSet:
+			n += uint32(len(x.Records))
+		case netflow.OptionsDataFlowSet:
+			n += uint32(len(x.Records))
+		}
+	}
+	return n
+}
+
+// observePacket records sequence number of decoded export packet
+func (t *sequenceTracker) observePacket(sampler string, msg any) {
+	switch pkt := msg.(type) {
+	case *netflowlegacy.PacketNetFlowV5:
+		t.observe(sequenceObservation{
+			key: sequenceKey{
+				sampler:  sampler,
+				protocol: "netflow_v5",
+				domain:   uint32(pkt.EngineType)<<8 | uint32(pkt.EngineId),
+			},
+			seq:         pkt.FlowSequence,
+			count:       uint32(pkt.Count),
+			countsFlows: true,
+			flows:       uint32(pkt.Count),
+			uptime:      pkt.SysUptime,
+		})
+	case *netflow.NFv9Packet:
+		t.observe(sequenceObservation{
+			key:    sequenceKey{sampler: sampler, protocol: "netflow_v9", domain: pkt.SourceId},
+			seq:    pkt.SequenceNumber,
+			count:  1,
+			flows:  dataRecords(pkt.FlowSets),
+			uptime: pkt.SystemUptime,
+		})
+	case *netflow.IPFIXPacket:
+		// sequence number of IPFIX is number of data records, templates are not counted
+		records := dataRecords(pkt.FlowSets)
+		t.observe(sequenceObservation{
+			key:         sequenceKey{sampler: sampler, protocol: "ipfix", domain: pkt.ObservationDomainId},
+			seq:         pkt.SequenceNumber,
+			count:       records,
+			countsFlows: true,
+			flows:       records,
+		})
+	case *sflow.Packet:
+		t.observe(sequenceObservation{
+			key:    sequenceKey{sampler: sampler, protocol: "sflow", domain: pkt.SubAgentId},
+			seq:    pkt.SequenceNumber,
+			count:  1,
+			flows:  pkt.SamplesCount,
+			uptime: pkt.Uptime,
+		})
+	}
+}
+
+// observeHeader records sequence number from header of export packet that could not be decoded
+// (e.g. because of missing template), so that it is not mistaken for lost packet. Truncated headers are ignored.
+func (t *sequenceTracker) observeHeader(sampler string, payload []byte) {
+	be := binary.BigEndian
+	switch protocol := exportProtocol(payload); protocol {
+	case "netflow_v5":
+		if len(payload) < 24 {
+			return
+		}
+		count := uint32(be.Uint16(payload[2:]))
+		t.observe(sequenceObservation{
+			key:         sequenceKey{sampler: sampler, protocol: protocol, domain: uint32(payload[20])<<8 | uint32(payload[21])},
+			seq:         be.Uint32(payload[16:]),
+			count:       count,
+			countsFlows: true,
+			uptime:      be.Uint32(payload[4:]),
+			undecoded:   true,
+		})
+	case "netflow_v9":
+		if len(payload) < 20 {
+			return
+		}
+		t.observe(sequenceObservation{
+			key:       sequenceKey{sampler: sampler, protocol: protocol, domain: be.Uint32(payload[16:])},
+			seq:       be.Uint32(payload[12:]),
+			count:     1,
+			uptime:    be.Uint32(payload[4:]),
+			undecoded: true,
+		})
+	case "ipfix":
+		if len(payload) < 16 {
+			return
+		}
+		t.observe(sequenceObservation{
+			key:          sequenceKey{sampler: sampler, protocol: protocol, domain: be.Uint32(payload[12:])},
+			seq:          be.Uint32(payload[8:]),
+			countsFlows:  true,
+			undecoded:    true,
+			unknownCount: true,
+		})
+	case "sflow":
+		if len(payload) < 8 {
+			return
+		}
+		// agent address is either IPv4 or IPv6
+		off := 12
+		switch be.Uint32(payload[4:]) {
+		case 1:
+		case 2:
+			off = 24
+		default:
+			return
+		}
+		if len(payload) < off+12 {
+			return
+		}
+		t.observe(sequenceObservation{
+			key:       sequenceKey{sampler: sampler, protocol: protocol, domain: be.Uint32(payload[off:])},
+			seq:       be.Uint32(payload[off+4:]),
+			count:     1,
+			uptime:    be.Uint32(payload[off+8:]),
+			undecoded: true,
+		})
+	}
+}
@@ -0,0 +1,185 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// v9Observation is NetFlow v9 packet with 10 flows, sequence numbers count packets
+func v9Observation(seq, uptime uint32) sequenceObservation {
+	return sequenceObservation{
+		key:    sequenceKey{sampler: "10.0.0.1", protocol: "netflow_v9"},
+		seq:    seq,
+		count:  1,
+		flows:  10,
+		uptime: uptime,
+	}
+}
+
+func sequenceCounters(st *sequenceTracker) map[string]float64 {
+	labels := []string{"10.0.0.1", "netflow_v9", "0"}
+	return map[string]float64{
+		"gaps":     testutil.ToFloat64(st.gaps.WithLabelValues(labels...)),
+		"reorders": testutil.ToFloat64(st.reorders.WithLabelValues(labels...)),
+		"resets":   testutil.ToFloat64(st.resets.WithLabelValues(labels...)),
+		"flows":    testutil.ToFloat64(st.lostFlows.WithLabelValues(labels...)),
+		"packets":  testutil.ToFloat64(st.lostPackets.WithLabelValues(labels...)),
+	}
+}
+
+func TestSequenceTrackerWrapAround(t *testing.T) {
+	st := newSequenceTracker("", time.Hour)
+	st.observe(v9Observation(0xfffffffe, 1000))
+	st.observe(v9Observation(0xffffffff, 1001))
+	st.observe(v9Observation(0, 1002))
+	assert.Equal(t, map[string]float64{"gaps": 0, "reorders": 0, "resets": 0, "flows": 0, "packets": 0}, sequenceCounters(st))
+}
+
+func TestSequenceTrackerReorder(t *testing.T) {
+	st := newSequenceTracker("", time.Hour)
+	st.observe(v9Observation(1, 1000))
+	st.observe(v9Observation(4, 1003))
+	// late packets fill the gap, so nothing is lost
+	st.observe(v9Observation(2, 1001))
+	st.observe(v9Observation(3, 1002))
+	for i := uint32(5); i < 30; i++ {
+		st.observe(v9Observation(i, 1000+i))
+	}
+	assert.Equal(t, map[string]float64{"gaps": 1, "reorders": 2, "resets": 0, "flows": 0, "packets": 0}, sequenceCounters(st))
+}
+
+func TestSequenceTrackerLoss(t *testing.T) {
+	st := newSequenceTracker("", time.Hour)
+	st.observe(v9Observation(1, 1000))
+	st.observe(v9Observation(4, 1003))
+	st.observe(v9Observation(3, 1002))
+	// gap is not considered lost until window passes
+	assert.Equal(t, 0.0, sequenceCounters(st)["packets"])
+	for i := uint32(5); i < 30; i++ {
+		st.observe(v9Observation(i, 1000+i))
+	}
+	assert.Equal(t, map[string]float64{"gaps": 1, "reorders": 1, "resets": 0, "flows": 10, "packets": 1}, sequenceCounters(st))
+
+	// sequence of NetFlow v5 counts flows, lost packets are estimated from average number of flows per packet
+	key := sequenceKey{sampler: "10.0.0.2", protocol: "netflow_v5", domain: 1}
+	v5 := func(seq uint32) sequenceObservation {
+		return sequenceObservation{key: key, seq: seq, count: 5, countsFlows: true, flows: 5, uptime: 1000 + seq}
+	}
+	st.observe(v5(0))
+	st.observe(v5(20))
+	for i := uint32(25); i < 150; i += 5 {
+		st.observe(v5(i))
+	}
+	assert.Equal(t, 15.0, testutil.ToFloat64(st.lostFlows.WithLabelValues("10.0.0.2", "netflow_v5", "1")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(st.lostPackets.WithLabelValues("10.0.0.2", "netflow_v5", "1")))
+}
+
+func TestSequenceTrackerReset(t *testing.T) {
+	st := newSequenceTracker("", time.Hour)
+	st.observe(v9Observation(1000, 500000))
+	st.observe(v9Observation(1002, 500002))
+	// exporter restarted, pending gap is lost
+	st.observe(v9Observation(0, 100))
+	st.observe(v9Observation(1, 101))
+	assert.Equal(t, map[string]float64{"gaps": 1, "reorders": 0, "resets": 1, "flows": 10, "packets": 1}, sequenceCounters(st))
+
+	// IPFIX does not carry uptime, reset is detected by how far sequence goes back
+	key := sequenceKey{sampler: "10.0.0.3", protocol: "ipfix"}
+	ipfix := func(seq uint32) sequenceObservation {
+		return sequenceObservation{key: key, seq: seq, count: 2, countsFlows: true, flows: 2}
+	}
+	st.observe(ipfix(100000))
+	st.observe(ipfix(100002))
+	st.observe(ipfix(99990))
+	st.observe(ipfix(0))
+	st.observe(ipfix(2))
+	assert.Equal(t, 1.0, testutil.ToFloat64(st.reorders.WithLabelValues("10.0.0.3", "ipfix", "0")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(st.resets.WithLabelValues("10.0.0.3", "ipfix", "0")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(st.gaps.WithLabelValues("10.0.0.3", "ipfix", "0")))
+}
+
+func TestSequenceTrackerUndecoded(t *testing.T) {
+	st := newSequenceTracker("", time.Hour)
+	// IPFIX header: version, length, export time, sequence and observation domain
+	ipfixHeader := func(seq uint32) []byte {
+		b := make([]byte, 16)
+		binary.BigEndian.PutUint16(b[0:], 10)
+		binary.BigEndian.PutUint16(b[2:], 16)
+		binary.BigEndian.PutUint32(b[8:], seq)
+		binary.BigEndian.PutUint32(b[12:], 7)
+		return b
+	}
+	key := sequenceKey{sampler: "10.0.0.4", protocol: "ipfix", domain: 7}
+	st.observe(sequenceObservation{key: key, seq: 0, count: 5, countsFlows: true, flows: 5})
+	// number of records in undecoded packet is not known, next packet continues where it ends
+	st.observeHeader("10.0.0.4", ipfixHeader(5))
+	st.observe(sequenceObservation{key: key, seq: 12, count: 3, countsFlows: true, flows: 3})
+	// but gap before undecoded packet is still detected
+	st.observeHeader("10.0.0.4", ipfixHeader(20))
+	st.observeHeader("10.0.0.4", ipfixHeader(20)[:12])
+	assert.Equal(t, 1.0, testutil.ToFloat64(st.gaps.WithLabelValues("10.0.0.4", "ipfix", "7")))
+
+	// sFlow with IPv6 agent address
+	b := make([]byte, 36)
+	binary.BigEndian.PutUint32(b[0:], 5)
+	binary.BigEndian.PutUint32(b[4:], 2)
+	binary.BigEndian.PutUint32(b[24:], 3)
+	for seq := uint32(1); seq < 4; seq++ {
+		binary.BigEndian.PutUint32(b[28:], seq)
+		binary.BigEndian.PutUint32(b[32:], 1000+seq)
+		st.observeHeader("10.0.0.5", b)
+	}
+	st.observe(sequenceObservation{key: sequenceKey{sampler: "10.0.0.5", protocol: "sflow", domain: 3}, seq: 4, count: 1, flows: 2, uptime: 1004})
+	assert.Equal(t, 0.0, testutil.ToFloat64(st.gaps.WithLabelValues("10.0.0.5", "sflow", "3")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(st.reorders.WithLabelValues("10.0.0.5", "sflow", "3")))
+}
+
+func TestSequenceTrackerRetention(t *testing.T) {
+	st := newSequenceTracker("", 10*time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.now = func() time.Time {
+		return now
+	}
+	other := v9Observation(0, 100)
+	other.key.sampler = "10.0.0.2"
+	st.observe(v9Observation(0, 100))
+	st.observe(v9Observation(2, 102))
+	st.observe(other)
+	now = now.Add(6 * time.Minute)
+	st.observe(v9Observation(3, 103))
+	now = now.Add(6 * time.Minute)
+	st.observe(v9Observation(4, 104))
+
+	// stream that did not send anything for longer than retention is removed together with its series
+	st.mu.Lock()
+	assert.Equal(t, 1, len(st.streams))
+	st.mu.Unlock()
+	assert.NoError(t, testutil.CollectAndCompare(st, strings.NewReader(`
+# HELP receiver_sequence_gaps The total number of gaps in sequence numbers of export packets.
+# TYPE receiver_sequence_gaps counter
+receiver_sequence_gaps{domain="0",protocol="netflow_v9",sampler="10.0.0.1"} 1
+`), "receiver_sequence_gaps"))
+
+	// removed when nothing arrives at all
+	now = now.Add(11 * time.Minute)
+	assert.NoError(t, testutil.CollectAndCompare(st, strings.NewReader(""), "receiver_sequence_gaps"))
+}
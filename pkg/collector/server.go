@@ -15,6 +15,7 @@
 package collector
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -29,41 +30,101 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// enricherInstance is enricher started under given identifier from pipeline
+type enricherInstance struct {
+	public.EnricherV2
+	id string
+	// optional, underlying enricher which reports hit or miss by itself
+	reporter public.HitReporter
+	// optional, underlying enricher which processes all flows of packet at once
+	batch batchEnricher
+
+	duration prometheus.Observer
+	hits     prometheus.Counter
+	misses   prometheus.Counter
+	errors   prometheus.Counter
+}
+
+// batchEnricher is implemented by enrichers which benefit from processing several flows together,
+// they get all flows decoded from single packet at once. Returned slice has error (or nil) for every flow.
+type batchEnricher interface {
+	EnrichBatch(flows []*public.Flow) []error
+}
+
+// samplingAware is implemented by enrichers which scale bytes of flows by sampling rate announced by exporter
+type samplingAware interface {
+	setSamplingRate(fn func(sampler string) uint32)
+}
+
 type col struct {
 	logger              *slog.Logger
 	ready               sync.WaitGroup
 	cfg                 *public.Config
 	filters             []FlowMatcher
-	enrichers           []public.Enricher
+	enrichers           []*enricherInstance
+	enricherMetrics     []prometheus.Collector
+	enricherHits        *prometheus.CounterVec
+	enricherMisses      *prometheus.CounterVec
+	enricherErrors      *prometheus.CounterVec
+	stageDuration       *prometheus.HistogramVec
+	utilizationDuration prometheus.Observer
+	ctx                 context.Context
+	cancel              context.CancelFunc
 	metrics             []*metricEntry
+	utilization         *utilizationTracker
 	droppedFlowsCounter *prometheus.CounterVec
 	totalFlowsCounter   *prometheus.CounterVec
 	scrapingSum         *prometheus.SummaryVec
+	receiver            *receiverMetrics
 	ap                  *utils.AutoFlowPipe
 	recv                *utils.UDPReceiver
 }
 
-func (c *col) Close() error {
+// Close stops receiving of flows first, so that no flow is being processed by enricher once it is closed
+func (c *col) Close() (err error) {
+	if c.recv != nil {
+		err = c.recv.Stop()
+	}
 	if c.ap != nil {
 		c.ap.Close()
 	}
-	if c.recv != nil {
-		return c.recv.Stop()
+	c.cancel()
+	c.closeEnrichers()
+	return err
+}
+
+func (c *col) closeEnrichers() {
+	for _, e := range c.enrichers {
+		if err := e.Close(); err != nil {
+			c.logger.Warn("unable to close enricher", "name", e.id, "err", err)
+		}
 	}
-	return nil
+	c.enrichers = nil
 }
 
 func (c *col) Describe(descs chan<- *prometheus.Desc) {
 	c.droppedFlowsCounter.Describe(descs)
 	c.totalFlowsCounter.Describe(descs)
+	c.enricherHits.Describe(descs)
+	c.enricherMisses.Describe(descs)
+	c.enricherErrors.Describe(descs)
+	c.stageDuration.Describe(descs)
+	c.receiver.Describe(descs)
 	c.scrapingSum.Describe(descs)
 	for _, m := range c.metrics {
 		m.Describe(descs)
 	}
+	if c.utilization != nil {
+		c.utilization.Describe(descs)
+	}
+	for _, em := range c.enricherMetrics {
+		em.Describe(descs)
+	}
 }
 
 func (c *col) Collect(ch chan<- prometheus.Metric) {
@@ -75,23 +136,38 @@ func (c *col) Collect(ch chan<- prometheus.Metric) {
 
 	c.droppedFlowsCounter.Collect(ch)
 	c.totalFlowsCounter.Collect(ch)
+	c.enricherHits.Collect(ch)
+	c.enricherMisses.Collect(ch)
+	c.enricherErrors.Collect(ch)
+	c.stageDuration.Collect(ch)
+	c.receiver.Collect(ch)
 	for _, m := range c.metrics {
 		m.Collect(ch)
 	}
+	if c.utilization != nil {
+		c.utilization.Collect(ch)
+	}
+	for _, em := range c.enricherMetrics {
+		em.Collect(ch)
+	}
 }
 
+// Publish processes flows decoded from single packet
 func (c *col) Publish(messages []*flowpb.FlowMessage) {
+	flows := make([]*public.Flow, 0, len(messages))
 	for _, msg := range messages {
-		c.Consume(msg)
+		if msg.Type == flowpb.FlowMessage_NETFLOW_V5 {
+			flows = append(flows, c.mapMsg(msg))
+		}
+	}
+	c.processFlows(flows)
+	for _, flow := range flows {
+		c.totalFlowsCounter.WithLabelValues(flow.AsIp("sampler").String()).Inc()
 	}
 }
 
 func (c *col) Consume(msg *flowpb.FlowMessage) {
-	if msg.Type == flowpb.FlowMessage_NETFLOW_V5 {
-		flow := c.mapMsg(msg)
-		c.processFlow(flow)
-		c.totalFlowsCounter.WithLabelValues(flow.AsIp("sampler").String()).Inc()
-	}
+	c.Publish([]*flowpb.FlowMessage{msg})
 }
 
 func (c *col) waitUntilReady() {
@@ -103,17 +179,23 @@ func (c *col) Run() (err error) {
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = c.Close()
+	}()
 	if c.recv, err = utils.NewUDPReceiver(&utils.UDPReceiverConfig{
 		Workers:   2,
 		Sockets:   1,
 		Blocking:  false,
 		QueueSize: 100,
+
+		ReceiverCallback: c.receiver,
 	}); err != nil {
 		return err
 	}
 	c.ap = utils.NewFlowPipe(&utils.PipeConfig{
-		Producer: &producerMetricAdapter{consumer: c},
+		Producer: &producerMetricAdapter{consumer: c, receiver: c.receiver},
 	})
+	c.receiver.inventory.setTemplateSource(c.ap.NetFlowPipe.GetTemplatesForAllSources)
 
 	host, port, err := net.SplitHostPort(c.cfg.NetflowEndpoint)
 	if err != nil {
@@ -125,11 +207,7 @@ func (c *col) Run() (err error) {
 	}
 	c.logger.Info("starting Netflow V5 listener", "host", host, "port", iport)
 
-	defer func() {
-		_ = c.Close()
-	}()
-
-	err = c.recv.Start(host, iport, c.ap.DecodeFlow)
+	err = c.recv.Start(host, iport, c.receiver.wrap(c.ap.DecodeFlow))
 	if err != nil {
 		return err
 	}
@@ -137,24 +215,60 @@ func (c *col) Run() (err error) {
 	return nil
 }
 
+// parseEnricherId splits enricher identifier in form of "type" or "type/name" into type and instance name
+func parseEnricherId(id string) (string, string) {
+	typ, name, _ := strings.Cut(id, "/")
+	return typ, name
+}
+
 func (c *col) startEnrichers() (err error) {
 	if c.cfg.Pipeline.Enrich != nil {
 		c.logger.Debug("starting enrichers", "enrichers", len(*c.cfg.Pipeline.Enrich))
-		for _, name := range *c.cfg.Pipeline.Enrich {
-			c.logger.Info("starting enricher", "name", name)
-			e := getEnricher(name)
+		// metrics of enricher types used more than once are distinguished by enricher label,
+		// "instance" would clash with target label of Prometheus
+		seen := map[string]bool{}
+		typeCount := map[string]int{}
+		for _, id := range *c.cfg.Pipeline.Enrich {
+			if seen[id] {
+				return fmt.Errorf("enricher %s is listed more than once", id)
+			}
+			seen[id] = true
+			typ, _ := parseEnricherId(id)
+			typeCount[typ]++
+		}
+		for _, id := range *c.cfg.Pipeline.Enrich {
+			c.logger.Info("starting enricher", "name", id)
+			typ, _ := parseEnricherId(id)
+			e, impl := newEnricher(typ)
 			if e == nil {
-				return fmt.Errorf("unknown enricher : %s", name)
+				return fmt.Errorf("unknown enricher : %s (available: %s)", typ, strings.Join(EnricherNames(), ", "))
 			}
 
-			if ext, ok := c.cfg.Extensions[name]; ok {
-				e.Configure(ext)
+			if sa, ok := impl.(samplingAware); ok && c.receiver != nil {
+				sa.setSamplingRate(c.receiver.inventory.samplingRate)
 			}
-			err = e.Start()
-			if err != nil {
-				return err
+			// each instance is configured from extension under its full identifier
+			if ext, ok := c.cfg.Extensions[id]; ok {
+				if err = e.Configure(ext); err != nil {
+					return fmt.Errorf("invalid configuration of enricher %s in extensions.%s: %w", typ, id, err)
+				}
+			}
+			if err = e.Start(c.ctx); err != nil {
+				return fmt.Errorf("unable to start enricher %s: %w", id, err)
+			}
+			reporter, _ := impl.(public.HitReporter)
+			batch, _ := impl.(batchEnricher)
+			c.enrichers = append(c.enrichers, &enricherInstance{EnricherV2: e, id: id, reporter: reporter, batch: batch})
+			// enrichers can expose their own metrics
+			if em, ok := impl.(prometheus.Collector); ok {
+				if typeCount[typ] > 1 {
+					em = prometheus.WrapCollectorWith(prometheus.Labels{"enricher": id}, em)
+				}
+				if len(c.cfg.Pipeline.Metrics.Prefix) > 0 {
+					em = prometheus.WrapCollectorWithPrefix(c.cfg.Pipeline.Metrics.Prefix+"_", em)
+				}
+				c.enricherMetrics = append(c.enricherMetrics, em)
 			}
-			c.enrichers = append(c.enrichers, e)
 		}
 	}
 	return nil
@@ -176,6 +290,12 @@ func (c *col) startFilters() error {
 
 func (c *col) start() (err error) {
 	defer c.ready.Done()
+	// enrichers started before failure would leak otherwise
+	defer func() {
+		if err != nil {
+			c.closeEnrichers()
+		}
+	}()
 
 	c.totalFlowsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: c.cfg.Pipeline.Metrics.Prefix,
@@ -189,12 +309,42 @@ func (c *col) start() (err error) {
 		Name:      "dropped_flows",
 		Help:      "The total number of dropped flows.",
 	}, []string{"sampler"})
+	c.enricherHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.cfg.Pipeline.Metrics.Prefix,
+		Subsystem: "server",
+		Name:      "enricher_hits",
+		Help:      "The total number of flows to which enricher added or updated any attribute.",
+	}, []string{"enricher"})
+	c.enricherMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.cfg.Pipeline.Metrics.Prefix,
+		Subsystem: "server",
+		Name:      "enricher_misses",
+		Help:      "The total number of flows that enricher left unchanged.",
+	}, []string{"enricher"})
+	c.enricherErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.cfg.Pipeline.Metrics.Prefix,
+		Subsystem: "server",
+		Name:      "enricher_errors",
+		Help:      "The total number of flows that enricher failed to process.",
+	}, []string{"enricher"})
+	c.stageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: c.cfg.Pipeline.Metrics.Prefix,
+		Subsystem: "server",
+		Name:      "stage_duration_seconds",
+		Help:      "Time spent by processing flow in single stage of pipeline.",
+		Buckets:   prometheus.ExponentialBuckets(0.000001, 4, 10),
+	}, []string{"stage", "name"})
 	c.scrapingSum = prometheus.NewSummaryVec(prometheus.SummaryOpts{
 		Namespace: c.cfg.Pipeline.Metrics.Prefix,
 		Subsystem: "server",
 		Name:      "scrape",
 		Help:      "The summary of time spent by scraping in microseconds",
 	}, []string{})
+	inventory, err := newExporterInventory(c.cfg.Pipeline.Metrics.Prefix, c.cfg.Exporters)
+	if err != nil {
+		return err
+	}
+	c.receiver = newReceiverMetrics(c.cfg.Pipeline.Metrics.Prefix, c.logger.With("component", "receiver"), inventory)
 	if err = c.startFilters(); err != nil {
 		return err
 	}
@@ -210,6 +360,16 @@ func (c *col) start() (err error) {
 		me.init(c.cfg.Pipeline.Metrics.Prefix, &metric, c.cfg.FlushInterval)
 		c.metrics = append(c.metrics, me)
 	}
+	if c.cfg.Pipeline.Metrics.Utilization != nil {
+		c.logger.Info("tracking interface utilization")
+		if c.utilization, err = newUtilizationTracker(c.cfg.Pipeline.Metrics.Prefix,
+			c.cfg.Pipeline.Metrics.Utilization, c.cfg.FlushInterval); err != nil {
+			return err
+		}
+		c.utilization.samplingRate = c.receiver.inventory.samplingRate
+	}
+
+	c.initStageMetrics()
 
 	if c.cfg.TelemetryEndpoint != nil {
 		prometheus.MustRegister(c)
@@ -219,6 +379,7 @@ func (c *col) start() (err error) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("OK"))
 		})
+		http.Handle("/exporters", c.receiver.inventory)
 
 		c.logger.Info("starting metrics server", "address", *c.cfg.TelemetryEndpoint)
 		go func() {
@@ -235,18 +396,106 @@ func (c *col) start() (err error) {
 	return nil
 }
 
+// initStageMetrics resolves per-stage metrics upfront, so that processFlow does not need to look them up
+func (c *col) initStageMetrics() {
+	for i := range c.filters {
+		c.filters[i].duration = c.stageDuration.WithLabelValues("filter", strconv.Itoa(i))
+	}
+	for _, en := range c.enrichers {
+		en.duration = c.stageDuration.WithLabelValues("enricher", en.id)
+		en.hits = c.enricherHits.WithLabelValues(en.id)
+		en.misses = c.enricherMisses.WithLabelValues(en.id)
+		en.errors = c.enricherErrors.WithLabelValues(en.id)
+	}
+	for _, m := range c.metrics {
+		m.duration = c.stageDuration.WithLabelValues("metric", m.opts.Name)
+	}
+	if c.utilization != nil {
+		c.utilizationDuration = c.stageDuration.WithLabelValues("utilization", "")
+	}
+}
+
 func (c *col) processFlow(flow *public.Flow) {
-	for _, m := range c.filters {
-		if m.fn(flow) {
-			c.droppedFlowsCounter.WithLabelValues(flow.AsIp("sampler").String()).Inc()
-			return
+	c.processFlows([]*public.Flow{flow})
+}
+
+// processFlows runs flows through pipeline, stage by stage, so that batch enrichers can process them together
+func (c *col) processFlows(flows []*public.Flow) {
+	kept := make([]*public.Flow, 0, len(flows))
+next:
+	for _, flow := range flows {
+		for _, m := range c.filters {
+			start := time.Now()
+			drop := m.fn(flow)
+			m.duration.Observe(time.Since(start).Seconds())
+			if drop {
+				c.droppedFlowsCounter.WithLabelValues(flow.AsIp("sampler").String()).Inc()
+				continue next
+			}
 		}
+		kept = append(kept, flow)
+	}
+	if len(kept) == 0 {
+		return
 	}
 	for _, en := range c.enrichers {
-		en.Enrich(flow)
+		if en.batch != nil {
+			c.enrichBatch(en, kept)
+			continue
+		}
+		for _, flow := range kept {
+			start := time.Now()
+			var hit bool
+			var err error
+			if en.reporter != nil {
+				hit, err = en.reporter.EnrichHit(flow)
+			} else {
+				updates := flow.Updates()
+				err = en.Enrich(flow)
+				hit = flow.Updates() > updates
+			}
+			en.duration.Observe(time.Since(start).Seconds())
+			c.countEnrichResult(en, hit, err)
+		}
 	}
-	for _, m := range c.metrics {
-		m.apply(flow)
+	for _, flow := range kept {
+		for _, m := range c.metrics {
+			start := time.Now()
+			m.apply(flow)
+			m.duration.Observe(time.Since(start).Seconds())
+		}
+		if c.utilization != nil {
+			start := time.Now()
+			c.utilization.apply(flow)
+			c.utilizationDuration.Observe(time.Since(start).Seconds())
+		}
+	}
+}
+
+// enrichBatch passes all flows to batch enricher at once, time is split evenly among flows
+func (c *col) enrichBatch(en *enricherInstance, flows []*public.Flow) {
+	updates := make([]int, len(flows))
+	for i, flow := range flows {
+		updates[i] = flow.Updates()
+	}
+	start := time.Now()
+	errs := en.batch.EnrichBatch(flows)
+	perFlow := time.Since(start).Seconds() / float64(len(flows))
+	for i, flow := range flows {
+		en.duration.Observe(perFlow)
+		c.countEnrichResult(en, flow.Updates() > updates[i], errs[i])
+	}
+}
+
+func (c *col) countEnrichResult(en *enricherInstance, hit bool, err error) {
+	switch {
+	case err != nil:
+		en.errors.Inc()
+		c.logger.Debug("enricher failed", "name", en.id, "err", err)
+	case hit:
+		en.hits.Inc()
+	default:
+		en.misses.Inc()
 	}
 }
 
@@ -263,6 +512,15 @@ func (c *col) mapMsg(msg *flowpb.FlowMessage) *public.Flow {
 	f.AddAttr("proto", msg.Proto)
 	f.AddAttr("source_port", msg.SrcPort)
 	f.AddAttr("destination_port", msg.DstPort)
+	if msg.Proto == 1 || msg.Proto == 58 {
+		icmpType, icmpCode := msg.IcmpType, msg.IcmpCode
+		// NetFlow v5 has no ICMP fields, exporters encode type and code in destination port instead
+		if msg.Type == flowpb.FlowMessage_NETFLOW_V5 {
+			icmpType, icmpCode = msg.DstPort>>8, msg.DstPort&0xff
+		}
+		f.AddAttr("icmp_type", icmpType)
+		f.AddAttr("icmp_code", icmpCode)
+	}
 	f.AddAttr("input_interface", msg.InIf)
 	f.AddAttr("output_interface", msg.OutIf)
 	f.AddAttr("next_hop", msg.NextHop)
@@ -277,9 +535,10 @@ func New(cfg *public.Config, logger *slog.Logger) public.Collector {
 		logger:    logger,
 		cfg:       cfg,
 		filters:   []FlowMatcher{},
-		enrichers: []public.Enricher{},
+		enrichers: []*enricherInstance{},
 		metrics:   []*metricEntry{},
 	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 	c.ready.Add(1)
 	return c
 }
@@ -15,6 +15,8 @@
 package collector
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -23,6 +25,8 @@ import (
 	"github.com/maxmind/mmdbwriter"
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/rkosegi/ipfix-collector/pkg/public"
 	"github.com/stretchr/testify/assert"
@@ -192,4 +196,86 @@ func TestServer(t *testing.T) {
 	m := &dto.Metric{}
 	assert.NoError(t, c.(*col).totalFlowsCounter.WithLabelValues("127.0.0.1").Write(m))
 	assert.Equal(t, float64(1), m.Counter.GetValue())
+
+	// every stage of pipeline observed the flow
+	m = &dto.Metric{}
+	assert.NoError(t, c.(*col).stageDuration.WithLabelValues("filter", "0").(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(1), m.Histogram.GetSampleCount())
+	m = &dto.Metric{}
+	assert.NoError(t, c.(*col).stageDuration.WithLabelValues("enricher", "maxmind_asn").(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(1), m.Histogram.GetSampleCount())
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.(*col).enricherHits.WithLabelValues("maxmind_asn")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.(*col).enricherMisses.WithLabelValues("maxmind_asn")))
+
+	// address missing in database is a miss, even though country is set to "Unknown"
+	c.(*col).Publish([]*flowpb.FlowMessage{{
+		Type:           flowpb.FlowMessage_NETFLOW_V5,
+		Packets:        1,
+		SamplerAddress: []byte{127, 0, 0, 1},
+		SrcAddr:        []byte{9, 9, 9, 9},
+		DstAddr:        []byte{192, 168, 1, 2},
+		Proto:          0x11,
+		SrcAs:          20,
+	}})
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.(*col).enricherHits.WithLabelValues("maxmind_country")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.(*col).enricherMisses.WithLabelValues("maxmind_country")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.(*col).enricherMisses.WithLabelValues("maxmind_asn")))
+}
+
+// batchingEnricher implements batchEnricher, it records size of every batch
+type batchingEnricher struct {
+	batches []int
+}
+
+func (b *batchingEnricher) Close() error                           { return nil }
+func (b *batchingEnricher) Configure(map[string]interface{}) error { return nil }
+func (b *batchingEnricher) Start(context.Context) error            { return nil }
+func (b *batchingEnricher) Enrich(flow *public.Flow) error {
+	return b.EnrichBatch([]*public.Flow{flow})[0]
+}
+func (b *batchingEnricher) EnrichBatch(flows []*public.Flow) []error {
+	b.batches = append(b.batches, len(flows))
+	errs := make([]error, len(flows))
+	for i, flow := range flows {
+		switch i {
+		case 0:
+			flow.AddAttr("batched", true)
+		case 1:
+			errs[i] = errors.New("failed")
+		}
+	}
+	return errs
+}
+
+func TestPublishBatch(t *testing.T) {
+	instance := &batchingEnricher{}
+	assert.NoError(t, RegisterEnricherV2("test_batch", func() public.EnricherV2 { return instance }))
+	t.Cleanup(func() {
+		unregisterEnricher("test_batch")
+	})
+	c := New(&public.Config{
+		Pipeline: public.Pipeline{
+			Enrich: &[]string{"test_batch"},
+			Filter: &[]public.FlowMatchRule{{IsUint32: strPtr("10"), Match: "source_as"}},
+		},
+	}, baseLogger).(*col)
+	assert.NoError(t, c.start())
+	msgs := make([]*flowpb.FlowMessage, 4)
+	for i := range msgs {
+		msgs[i] = &flowpb.FlowMessage{
+			Type:           flowpb.FlowMessage_NETFLOW_V5,
+			SamplerAddress: []byte{127, 0, 0, 1},
+			SrcAddr:        []byte{10, 0, 0, byte(i)},
+			DstAddr:        []byte{10, 0, 0, 254},
+		}
+	}
+	// dropped flow does not reach enricher
+	msgs[3].SrcAs = 10
+	c.Publish(msgs)
+	assert.Equal(t, []int{3}, instance.batches)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.enricherHits.WithLabelValues("test_batch")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.enricherErrors.WithLabelValues("test_batch")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.enricherMisses.WithLabelValues("test_batch")))
+	assert.Equal(t, 4.0, testutil.ToFloat64(c.totalFlowsCounter.WithLabelValues("127.0.0.1")))
+	assert.NoError(t, c.Close())
 }
@@ -0,0 +1,149 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// fileFollower follows file in similar way as "tail -F" does.
+// Rotation (file is replaced) and truncation are detected by polling file metadata.
+type fileFollower struct {
+	path         string
+	pollInterval time.Duration
+	// when true, existing content of file is read, otherwise only lines appended after start are processed
+	fromStart bool
+	logger    *slog.Logger
+}
+
+// follow reads lines from file until context is cancelled or an error occurs.
+func (t *fileFollower) follow(ctx context.Context, lineFn func(string)) error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if !t.fromStart {
+		if _, err = f.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	var partial strings.Builder
+	// drain reads all complete lines that are available
+	drain := func() error {
+		for {
+			s, err := r.ReadString('\n')
+			partial.WriteString(s)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			lineFn(strings.TrimRight(partial.String(), "\r\n"))
+			partial.Reset()
+		}
+	}
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err = drain(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		nfi, err := os.Stat(t.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// rotated, but new file was not created yet
+				continue
+			}
+			return err
+		}
+		if !os.SameFile(fi, nfi) {
+			t.logger.Info("file rotated", "path", t.path)
+			if err = drain(); err != nil {
+				return err
+			}
+			nf, err := os.Open(t.path)
+			if err != nil {
+				return err
+			}
+			_ = f.Close()
+			f = nf
+			fi = nfi
+			r.Reset(f)
+			partial.Reset()
+			continue
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if nfi.Size() < pos-int64(r.Buffered()) {
+			t.logger.Info("file truncated", "path", t.path)
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			r.Reset(f)
+			partial.Reset()
+		}
+	}
+}
+
+// run follows file until context is cancelled, restarting follower with backoff when it fails.
+func (t *fileFollower) run(ctx context.Context, lineFn func(string), onRestart func(error)) {
+	backoff := t.pollInterval
+	for {
+		started := time.Now()
+		err := t.follow(ctx, lineFn)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			t.logger.Warn("unable to follow file", "path", t.path, "err", err)
+		}
+		onRestart(err)
+		if time.Since(started) > 30*time.Second {
+			backoff = t.pollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		// after restart, file is read from beginning as it is most likely new
+		t.fromStart = true
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
@@ -0,0 +1,126 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lineCollector struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (lc *lineCollector) add(line string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.lines = append(lc.lines, line)
+}
+
+func (lc *lineCollector) get() []string {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return append([]string{}, lc.lines...)
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(data)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestFileFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	appendFile(t, path, "old line\n")
+	ff := &fileFollower{
+		path:         path,
+		pollInterval: 10 * time.Millisecond,
+		logger:       baseLogger,
+	}
+	lc := &lineCollector{}
+	ctx, cancel := context.WithCancel(context.Background())
+	restarts := 0
+	done := make(chan struct{})
+	go func() {
+		ff.run(ctx, lc.add, func(error) {
+			restarts++
+		})
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// partial line is only emitted once complete
+	appendFile(t, path, "line 1\nline")
+	assert.Eventually(t, func() bool {
+		return len(lc.get()) == 1
+	}, time.Second, 10*time.Millisecond)
+	appendFile(t, path, " 2\n")
+	assert.Eventually(t, func() bool {
+		return len(lc.get()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// truncation
+	assert.NoError(t, os.Truncate(path, 0))
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "line 3\n")
+	assert.Eventually(t, func() bool {
+		return len(lc.get()) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// rotation
+	assert.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path+".1", "line 4\n")
+	appendFile(t, path, "line 5\n")
+	assert.Eventually(t, func() bool {
+		return len(lc.get()) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.Equal(t, []string{"line 1", "line 2", "line 3", "line 4", "line 5"}, lc.get())
+	assert.Equal(t, 0, restarts)
+}
+
+func TestFileFollowerMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	ff := &fileFollower{
+		path:         path,
+		pollInterval: 10 * time.Millisecond,
+		logger:       baseLogger,
+	}
+	lc := &lineCollector{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var restarts sync.WaitGroup
+	restarts.Add(1)
+	once := sync.Once{}
+	go ff.run(ctx, lc.add, func(error) {
+		once.Do(restarts.Done)
+	})
+	restarts.Wait()
+	appendFile(t, path, "line 1\n")
+	assert.Eventually(t, func() bool {
+		return len(lc.get()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
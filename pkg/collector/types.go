@@ -28,6 +28,8 @@ type metricEntry struct {
 	opts    prometheus.CounterOpts
 	labels  []*labelProcessor
 	metrics *ttlcache.Cache[string, prometheus.Counter]
+	// time spent by applying flow to this metric
+	duration prometheus.Observer
 }
 
 type FilterFn func(flow *public.Flow) bool
@@ -35,6 +37,8 @@ type FilterFn func(flow *public.Flow) bool
 type FlowMatcher struct {
 	rule *public.FlowMatchRule
 	fn   FilterFn
+	// time spent by evaluating this filter
+	duration prometheus.Observer
 }
 
 type labelProcessor struct {
@@ -0,0 +1,197 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+)
+
+var utilizationLabels = []string{"sampler", "interface", "name", "direction"}
+
+type ifCounterKey struct {
+	sampler   string
+	ifIndex   uint32
+	direction string
+}
+
+// ifCounter holds bytes seen on interface in per-second buckets of sliding window
+type ifCounter struct {
+	name     string
+	speed    uint64
+	buckets  []uint64
+	slot     int64
+	lastSeen time.Time
+}
+
+func (c *ifCounter) advance(now int64) {
+	if now <= c.slot {
+		return
+	}
+	n := int64(len(c.buckets))
+	if now-c.slot >= n {
+		clear(c.buckets)
+	} else {
+		for s := c.slot + 1; s <= now; s++ {
+			c.buckets[s%n] = 0
+		}
+	}
+	c.slot = now
+}
+
+func (c *ifCounter) add(now int64, bytes uint64) {
+	c.advance(now)
+	c.buckets[now%int64(len(c.buckets))] += bytes
+}
+
+func (c *ifCounter) sum(now int64) uint64 {
+	c.advance(now)
+	var total uint64
+	for _, b := range c.buckets {
+		total += b
+	}
+	return total
+}
+
+type utilizationTracker struct {
+	window time.Duration
+	expiry time.Duration
+	speeds map[string]map[string]uint64
+	now    func() time.Time
+	// returns sampling rate announced by exporter, zero if unknown
+	samplingRate   func(sampler string) uint32
+	throughputDesc *prometheus.Desc
+	percentDesc    *prometheus.Desc
+	mu             sync.Mutex
+	counters       map[ifCounterKey]*ifCounter
+}
+
+func newUtilizationTracker(prefix string, spec *public.UtilizationSpec, flushInterval int) (*utilizationTracker, error) {
+	window := time.Minute
+	if len(spec.Window) > 0 {
+		var err error
+		if window, err = time.ParseDuration(spec.Window); err != nil {
+			return nil, err
+		}
+	}
+	if window < time.Second {
+		window = time.Second
+	}
+	speeds := spec.Speeds
+	if speeds == nil {
+		speeds = map[string]map[string]uint64{}
+	}
+	return &utilizationTracker{
+		window: window,
+		expiry: time.Duration(flushInterval) * time.Second,
+		speeds: speeds,
+		now:    time.Now,
+		throughputDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(prefix, "interface", "throughput_bps"),
+			"Throughput of interface in bits per second, computed from flows over sliding window",
+			utilizationLabels, nil),
+		percentDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(prefix, "interface", "utilization_percent"),
+			"Utilization of interface in percent of its speed, computed from flows over sliding window",
+			utilizationLabels, nil),
+		counters: map[ifCounterKey]*ifCounter{},
+	}, nil
+}
+
+func (u *utilizationTracker) configuredSpeed(sampler string, ifIndex uint32) uint64 {
+	key := strconv.FormatUint(uint64(ifIndex), 10)
+	if m, ok := u.speeds[sampler]; ok {
+		if speed, ok := m[key]; ok {
+			return speed
+		}
+	}
+	return u.speeds["default"][key]
+}
+
+func (u *utilizationTracker) account(flow *public.Flow, sampler, attr, direction string, bytes uint64, now time.Time) {
+	ifIndex := flow.AsUint32(attr)
+	if ifIndex == nil {
+		return
+	}
+	key := ifCounterKey{sampler: sampler, ifIndex: *ifIndex, direction: direction}
+	c, ok := u.counters[key]
+	if !ok {
+		c = &ifCounter{
+			buckets: make([]uint64, int(u.window/time.Second)),
+			slot:    now.Unix(),
+		}
+		u.counters[key] = c
+	}
+	if name := flow.AsString(attr + "_name"); name != nil {
+		c.name = *name
+	}
+	// discovered speed takes precedence over configured one
+	if speed, ok := flow.Raw(attr + "_speed").(uint64); ok && speed > 0 {
+		c.speed = speed
+	} else {
+		c.speed = u.configuredSpeed(sampler, *ifIndex)
+	}
+	c.lastSeen = now
+	c.add(now.Unix(), bytes)
+}
+
+func (u *utilizationTracker) apply(flow *public.Flow) {
+	bytes, ok := flow.Raw("bytes").(uint64)
+	if !ok {
+		return
+	}
+	sampler := ""
+	if ip := flow.AsIp("sampler"); ip != nil {
+		sampler = ip.String()
+	}
+	// sampled flow stands for this many flows
+	if u.samplingRate != nil {
+		if rate := u.samplingRate(sampler); rate > 1 {
+			bytes *= uint64(rate)
+		}
+	}
+	now := u.now()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.account(flow, sampler, "input_interface", "in", bytes, now)
+	u.account(flow, sampler, "output_interface", "out", bytes, now)
+}
+
+func (u *utilizationTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- u.throughputDesc
+	ch <- u.percentDesc
+}
+
+func (u *utilizationTracker) Collect(ch chan<- prometheus.Metric) {
+	now := u.now()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for key, c := range u.counters {
+		if now.Sub(c.lastSeen) > u.expiry {
+			delete(u.counters, key)
+			continue
+		}
+		bps := float64(c.sum(now.Unix())) * 8 / u.window.Seconds()
+		labels := []string{key.sampler, strconv.FormatUint(uint64(key.ifIndex), 10), c.name, key.direction}
+		ch <- prometheus.MustNewConstMetric(u.throughputDesc, prometheus.GaugeValue, bps, labels...)
+		if c.speed > 0 {
+			ch <- prometheus.MustNewConstMetric(u.percentDesc, prometheus.GaugeValue, bps*100/float64(c.speed), labels...)
+		}
+	}
+}
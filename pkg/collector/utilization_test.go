@@ -0,0 +1,107 @@
+//	Copyright 2026 Richard Kosegi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rkosegi/ipfix-collector/pkg/public"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUtilization(t *testing.T) {
+	u, err := newUtilizationTracker("netflow", &public.UtilizationSpec{
+		Window: "10s",
+		Speeds: map[string]map[string]uint64{
+			"default": {
+				"2": 1000,
+			},
+		},
+	}, 60)
+	assert.NoError(t, err)
+	now := time.Unix(1000, 0)
+	u.now = func() time.Time {
+		return now
+	}
+	f := &public.Flow{}
+	f.AddAttr("sampler", []byte{10, 0, 0, 1})
+	f.AddAttr("input_interface", uint32(1))
+	f.AddAttr("input_interface_name", "ether1")
+	f.AddAttr("input_interface_speed", uint64(8000))
+	f.AddAttr("output_interface", uint32(2))
+	f.AddAttr("bytes", uint64(500))
+	u.apply(f)
+	now = now.Add(5 * time.Second)
+	u.apply(f)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(u)
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP netflow_interface_throughput_bps Throughput of interface in bits per second, computed from flows over sliding window
+# TYPE netflow_interface_throughput_bps gauge
+netflow_interface_throughput_bps{direction="in",interface="1",name="ether1",sampler="10.0.0.1"} 800
+netflow_interface_throughput_bps{direction="out",interface="2",name="",sampler="10.0.0.1"} 800
+# HELP netflow_interface_utilization_percent Utilization of interface in percent of its speed, computed from flows over sliding window
+# TYPE netflow_interface_utilization_percent gauge
+netflow_interface_utilization_percent{direction="in",interface="1",name="ether1",sampler="10.0.0.1"} 10
+netflow_interface_utilization_percent{direction="out",interface="2",name="",sampler="10.0.0.1"} 80
+`)))
+
+	// first sample leaves the window
+	now = now.Add(7 * time.Second)
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP netflow_interface_throughput_bps Throughput of interface in bits per second, computed from flows over sliding window
+# TYPE netflow_interface_throughput_bps gauge
+netflow_interface_throughput_bps{direction="in",interface="1",name="ether1",sampler="10.0.0.1"} 400
+netflow_interface_throughput_bps{direction="out",interface="2",name="",sampler="10.0.0.1"} 400
+`), "netflow_interface_throughput_bps"))
+
+	// idle interfaces are eventually forgotten
+	now = now.Add(2 * time.Minute)
+	count, err := testutil.GatherAndCount(reg)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestUtilizationSampling(t *testing.T) {
+	u, err := newUtilizationTracker("netflow", &public.UtilizationSpec{Window: "10s"}, 60)
+	assert.NoError(t, err)
+	u.now = func() time.Time {
+		return time.Unix(1000, 0)
+	}
+	u.samplingRate = func(sampler string) uint32 {
+		if sampler == "10.0.0.1" {
+			return 100
+		}
+		return 0
+	}
+	for _, sampler := range [][]byte{{10, 0, 0, 1}, {10, 0, 0, 2}} {
+		f := &public.Flow{}
+		f.AddAttr("sampler", sampler)
+		f.AddAttr("input_interface", uint32(1))
+		f.AddAttr("bytes", uint64(500))
+		u.apply(f)
+	}
+	assert.NoError(t, testutil.CollectAndCompare(u, strings.NewReader(`
+# HELP netflow_interface_throughput_bps Throughput of interface in bits per second, computed from flows over sliding window
+# TYPE netflow_interface_throughput_bps gauge
+netflow_interface_throughput_bps{direction="in",interface="1",name="",sampler="10.0.0.1"} 40000
+netflow_interface_throughput_bps{direction="in",interface="1",name="",sampler="10.0.0.2"} 400
+`)))
+}
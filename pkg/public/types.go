@@ -15,6 +15,8 @@
 package public
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"net"
 )
@@ -25,6 +27,8 @@ type Collector interface {
 
 type Flow struct {
 	attrs map[string]interface{}
+	// number of times any attribute was added or updated
+	updates int
 }
 
 type Enricher interface {
@@ -34,12 +38,84 @@ type Enricher interface {
 	Enrich(*Flow)
 }
 
+// EnricherFactory creates new, unconfigured instance of Enricher
+type EnricherFactory func() Enricher
+
+// EnricherV2 is enricher that reports configuration and per-flow errors instead of panicking.
+type EnricherV2 interface {
+	io.Closer
+	// Configure applies configuration from extensions section, error describes what is wrong with it
+	Configure(map[string]interface{}) error
+	// Start prepares enricher for use. Context is cancelled when collector is stopping.
+	Start(context.Context) error
+	// Enrich adds attributes to flow. Error does not stop processing of flow, it is counted and logged.
+	Enrich(*Flow) error
+}
+
+// EnricherV2Factory creates new, unconfigured instance of EnricherV2
+type EnricherV2Factory func() EnricherV2
+
+// HitReporter can be implemented by enricher of either kind, that knows whether it found data for flow,
+// e.g. answered lookup from cache or found address in database. Collector then calls EnrichHit instead of Enrich
+// and counts flow as hit or miss accordingly. Otherwise, flow is counted as hit when any attribute was added or updated.
+type HitReporter interface {
+	EnrichHit(*Flow) (bool, error)
+}
+
+type enricherAdapter struct {
+	e Enricher
+}
+
+// AdaptEnricher wraps Enricher, so that it can be used as EnricherV2.
+// Panic raised by Configure is returned as error, Enrich never fails.
+func AdaptEnricher(e Enricher) EnricherV2 {
+	return &enricherAdapter{e: e}
+}
+
+func (a *enricherAdapter) Configure(cfg map[string]interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	a.e.Configure(cfg)
+	return nil
+}
+
+func (a *enricherAdapter) Start(context.Context) error {
+	return a.e.Start()
+}
+
+func (a *enricherAdapter) Enrich(flow *Flow) error {
+	a.e.Enrich(flow)
+	return nil
+}
+
+func (a *enricherAdapter) Close() error {
+	return a.e.Close()
+}
+
+// Unwrap returns wrapped Enricher
+func (a *enricherAdapter) Unwrap() Enricher {
+	return a.e
+}
+
 // AddAttr adds or updates attribute value
 func (f *Flow) AddAttr(attr string, v interface{}) {
 	if f.attrs == nil {
 		f.attrs = make(map[string]interface{}, 0)
 	}
 	f.attrs[attr] = v
+	f.updates++
+}
+
+// Updates returns number of times any attribute was added or updated
+func (f *Flow) Updates() int {
+	return f.updates
 }
 
 // AsIp attempts to get attribute value as net.IP
@@ -65,6 +141,15 @@ func (f *Flow) Raw(attr string) interface{} {
 	return f.attrs[attr]
 }
 
+// Attrs returns copy of all attributes
+func (f *Flow) Attrs() map[string]interface{} {
+	attrs := make(map[string]interface{}, len(f.attrs))
+	for k, v := range f.attrs {
+		attrs[k] = v
+	}
+	return attrs
+}
+
 // AsUint32 attempts to get attribute value as uint32
 func (f *Flow) AsUint32(attr string) *uint32 {
 	if v, ok := f.attrs[attr]; ok {
@@ -80,6 +165,15 @@ type Config struct {
 	Pipeline          Pipeline                          `yaml:"pipeline"`
 	FlushInterval     int                               `yaml:"flush_interval"`
 	Extensions        map[string]map[string]interface{} `yaml:"extensions"`
+	Exporters         *ExportersSpec                    `yaml:"exporters,omitempty"`
+}
+
+type ExportersSpec struct {
+	// SilentAfter is duration without any packet after which exporter is considered silent, e.g. 5m
+	SilentAfter string `yaml:"silent_after,omitempty"`
+	// Retention is duration without any packet after which exporter is removed from inventory, e.g. 1h.
+	// Defaults to 12 times SilentAfter.
+	Retention string `yaml:"retention,omitempty"`
 }
 
 type Pipeline struct {
@@ -89,8 +183,16 @@ type Pipeline struct {
 }
 
 type MetricsConfig struct {
-	Prefix string       `yaml:"prefix"`
-	Items  []MetricSpec `yaml:"items"`
+	Prefix      string           `yaml:"prefix"`
+	Items       []MetricSpec     `yaml:"items"`
+	Utilization *UtilizationSpec `yaml:"utilization,omitempty"`
+}
+
+type UtilizationSpec struct {
+	// Window is duration of sliding window over which throughput is computed, e.g. 1m
+	Window string `yaml:"window,omitempty"`
+	// Speeds maps sampler address (or "default") to mapping of ifIndex to interface speed in bits per second
+	Speeds map[string]map[string]uint64 `yaml:"speeds,omitempty"`
 }
 
 type MetricSpec struct {